@@ -0,0 +1,22 @@
+/*
+Package autoload loads the .env file in the current working directory as soon as it is imported
+
+	import _ "github.com/andreGarvin/env/autoload"
+
+It uses env.LoadOnce, so calling env.LoadOnce again from main with no files is a no-op
+*/
+package autoload
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/andreGarvin/env"
+)
+
+func init() {
+	err := env.LoadOnce()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "env/autoload: %s\n", err)
+	}
+}
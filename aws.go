@@ -0,0 +1,429 @@
+package env
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AWSCredentials are the keys requests to AWS are signed with, SessionToken is only set for temporary credentials
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+/*
+AWSOptions are the options the AWS adapters share, anything left empty falls back to the env vars the
+AWS SDKs use. They are looked up in what the files and the adapters before loaded first, so an
+AWSWebIdentity adapter applied before authenticates the ones after it
+*/
+type AWSOptions struct {
+	// Region is the region of the service, by default $AWS_REGION or $AWS_DEFAULT_REGION
+	Region string
+
+	// Credentials returns the credentials to sign with, by default $AWS_ACCESS_KEY_ID, $AWS_SECRET_ACCESS_KEY
+	// and $AWS_SESSION_TOKEN
+	Credentials func() (AWSCredentials, error)
+
+	// Client is used for the requests, by default a client with a 10 second timeout
+	Client *http.Client
+
+	// Endpoint overrides the endpoint of the service, mostly for testing
+	Endpoint string
+}
+
+// SSMOptions configures SSMParameters
+type SSMOptions struct {
+	AWSOptions
+
+	// Path is the path of the parameters, like /myapp/prod/
+	Path string
+
+	// Recursive also gets the parameters below the sub paths of Path
+	Recursive bool
+
+	// PageSize is how many parameters are asked for at once, at most and by default 10
+	PageSize int
+}
+
+/*
+SSMParameters returns an adapter that exports the parameters under a path in the SSM Parameter Store,
+fetched a page at a time with GetParametersByPath and SecureStrings decrypted. The path is trimmed from
+the name of a parameter and the rest becomes its key like systemd credentials, so /myapp/prod/db-url
+under /myapp/prod/ becomes DB_URL and /myapp/prod/db/password with Recursive becomes DB_PASSWORD.
+
+The adapter can Push, with PutParameter. A key it pulled is written back to its parameter, keeping the
+type, and a new key becomes a parameter under Path with the key as the name, a SecureString when it is
+a secret (see Map.IsSecret) and a String otherwise
+*/
+func SSMParameters(opts SSMOptions) *Adapter {
+	// the parameters of the last pull by key, so Push writes a key back to the parameter it came from
+	var mu sync.Mutex
+	var params map[string]ssmParameter
+
+	a := awsAdapter("aws-ssm", func(loaded Reader) (*Map, error) {
+		if opts.Path == "" {
+			return nil, fmt.Errorf("could not get SSM parameters: no Path given")
+		}
+
+		pageSize := opts.PageSize
+		if pageSize <= 0 || pageSize > 10 {
+			pageSize = 10
+		}
+
+		emap := NewMap()
+		pulled := make(map[string]ssmParameter)
+
+		token := ""
+		for {
+			req := map[string]interface{}{
+				"Path":           opts.Path,
+				"Recursive":      opts.Recursive,
+				"WithDecryption": true,
+				"MaxResults":     pageSize,
+			}
+			if token != "" {
+				req["NextToken"] = token
+			}
+
+			var page struct {
+				Parameters []struct {
+					ssmParameter
+					Value string
+				}
+				NextToken string
+			}
+			err := opts.call(loaded, "ssm", "AmazonSSM.GetParametersByPath", req, &page)
+			if err != nil {
+				return nil, fmt.Errorf("could not get SSM parameters under %s: %s", opts.Path, err)
+			}
+
+			for _, param := range page.Parameters {
+				name := strings.TrimPrefix(strings.TrimPrefix(param.Name, opts.Path), "/")
+				emap.Set(envName(name), param.Value)
+				pulled[envName(name)] = param.ssmParameter
+			}
+
+			if page.NextToken == "" {
+				mu.Lock()
+				params = pulled
+				mu.Unlock()
+
+				return emap, nil
+			}
+			token = page.NextToken
+		}
+	})
+
+	a.Push = func(emap *Map) error {
+		if opts.Path == "" {
+			return fmt.Errorf("could not put SSM parameters: no Path given")
+		}
+
+		mu.Lock()
+		known := params
+		mu.Unlock()
+
+		for _, key := range emap.Keys() {
+			param, ok := known[key]
+			if !ok {
+				param = ssmParameter{Name: strings.TrimSuffix(opts.Path, "/") + "/" + key, Type: "String"}
+				if emap.IsSecret(key) {
+					param.Type = "SecureString"
+				}
+			}
+
+			req := map[string]interface{}{
+				"Name":      param.Name,
+				"Value":     emap.Map[key],
+				"Overwrite": true,
+			}
+
+			// an existing parameter keeps its type when none is given
+			if param.Type != "" {
+				req["Type"] = param.Type
+			}
+
+			var resp struct {
+				Version int
+			}
+			err := opts.call(Environ(), "ssm", "AmazonSSM.PutParameter", req, &resp)
+			if err != nil {
+				return fmt.Errorf("could not put SSM parameter %s: %s", param.Name, err)
+			}
+		}
+
+		return nil
+	}
+
+	return a
+}
+
+// ssmParameter is where a key pulled from SSM came from
+type ssmParameter struct {
+	Name string
+	Type string
+}
+
+// SecretsManagerOptions configures SecretsManagerSecrets, either Names or Prefix has to be set
+type SecretsManagerOptions struct {
+	AWSOptions
+
+	// Names are the names or ARNs of the secrets to export
+	Names []string
+
+	// Prefix exports every secret whose name starts with it, and is trimmed from the names
+	Prefix string
+
+	// PageSize is how many secrets are asked for at once, at most and by default 20
+	PageSize int
+}
+
+/*
+SecretsManagerSecrets returns an adapter that exports secrets from AWS Secrets Manager, fetched a page at
+a time with BatchGetSecretValue. The name of a secret, without Prefix, becomes its key like systemd
+credentials, so prod/db-password becomes PROD_DB_PASSWORD, or DB_PASSWORD with the prefix prod/.
+A secret in Names that can not be read is an error. Only secrets stored as a string are exported
+*/
+func SecretsManagerSecrets(opts SecretsManagerOptions) *Adapter {
+	return awsAdapter("aws-secrets-manager", func(loaded Reader) (*Map, error) {
+		if len(opts.Names) == 0 && opts.Prefix == "" {
+			return nil, fmt.Errorf("could not get secrets: no Names or Prefix given")
+		}
+
+		pageSize := opts.PageSize
+		if pageSize <= 0 || pageSize > 20 {
+			pageSize = 20
+		}
+
+		emap := NewMap()
+
+		// a list of names is sent a page at a time, a prefix is paged through by AWS
+		names := opts.Names
+		token := ""
+		for {
+			req := map[string]interface{}{}
+			if len(opts.Names) != 0 {
+				n := pageSize
+				if n > len(names) {
+					n = len(names)
+				}
+				req["SecretIdList"] = names[:n]
+				names = names[n:]
+			} else {
+				req["Filters"] = []map[string]interface{}{{"Key": "name", "Values": []string{opts.Prefix}}}
+				req["MaxResults"] = pageSize
+				if token != "" {
+					req["NextToken"] = token
+				}
+			}
+
+			var page struct {
+				SecretValues []struct {
+					Name         string
+					SecretString *string
+				}
+				Errors []struct {
+					SecretID  string `json:"SecretId"`
+					ErrorCode string
+					Message   string
+				}
+				NextToken string
+			}
+			err := opts.call(loaded, "secretsmanager", "secretsmanager.BatchGetSecretValue", req, &page)
+			if err != nil {
+				return nil, fmt.Errorf("could not get secrets: %s", err)
+			}
+
+			if len(page.Errors) != 0 {
+				e := page.Errors[0]
+				return nil, fmt.Errorf("could not get secret %s: %s: %s", e.SecretID, e.ErrorCode, e.Message)
+			}
+
+			for _, secret := range page.SecretValues {
+				if secret.SecretString == nil {
+					continue
+				}
+				emap.Set(envName(strings.TrimPrefix(secret.Name, opts.Prefix)), *secret.SecretString)
+			}
+
+			if len(opts.Names) != 0 && len(names) == 0 || len(opts.Names) == 0 && page.NextToken == "" {
+				return emap, nil
+			}
+			token = page.NextToken
+		}
+	})
+}
+
+// awsAdapter creates an adapter that reads its region and credentials from what was loaded before it
+func awsAdapter(name string, pull func(loaded Reader) (*Map, error)) *Adapter {
+	return loadedAdapter(name, true, pull)
+}
+
+// call sends a request to the JSON API of an AWS service, signed with Signature Version 4. What is not in
+// the options is looked up in loaded
+func (o AWSOptions) call(loaded Reader, service, target string, in, out interface{}) error {
+	region := firstNonEmpty(o.Region, getenv(loaded, "AWS_REGION"), getenv(loaded, "AWS_DEFAULT_REGION"))
+	if region == "" {
+		return fmt.Errorf("no Region given and AWS_REGION is not set")
+	}
+
+	getCredentials := o.Credentials
+	if getCredentials == nil {
+		getCredentials = func() (AWSCredentials, error) {
+			return awsEnvCredentials(loaded)
+		}
+	}
+	creds, err := getCredentials()
+	if err != nil {
+		return err
+	}
+
+	endpoint := o.Endpoint
+	if endpoint == "" {
+		endpoint = "https://" + service + "." + region + ".amazonaws.com"
+	}
+
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(endpoint, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", target)
+	signAWS(req, body, creds, region, service, time.Now())
+
+	client := o.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var awsErr struct {
+			Type         string `json:"__type"`
+			Message      string `json:"message"`
+			MessageUpper string `json:"Message"`
+		}
+		json.Unmarshal(data, &awsErr)
+
+		// the type is namespaced like com.amazonaws.ssm#ParameterNotFound
+		code := awsErr.Type[strings.LastIndex(awsErr.Type, "#")+1:]
+
+		return fmt.Errorf("%s %s: %s", resp.Status, code, firstNonEmpty(awsErr.Message, awsErr.MessageUpper))
+	}
+
+	return json.Unmarshal(data, out)
+}
+
+// awsEnvCredentials returns the credentials in the env vars the AWS SDKs use
+func awsEnvCredentials(r Reader) (AWSCredentials, error) {
+	creds := AWSCredentials{
+		AccessKeyID:     getenv(r, "AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: getenv(r, "AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    getenv(r, "AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return creds, fmt.Errorf("no Credentials given and AWS_ACCESS_KEY_ID or AWS_SECRET_ACCESS_KEY is not set")
+	}
+
+	return creds, nil
+}
+
+// signAWS signs the request with Signature Version 4, see https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_sigv-create-signed-request.html
+func signAWS(req *http.Request, body []byte, creds AWSCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	canonical := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		contentHash(body),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + contentHash([]byte(canonical))
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, hex.EncodeToString(hmacSHA256(key, toSign))))
+}
+
+// canonicalQuery sorts the query by name and then value, with spaces escaped as %20 instead of +
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for name, values := range query {
+		for _, val := range values {
+			pairs = append(pairs, url.QueryEscape(name)+"="+url.QueryEscape(val))
+		}
+	}
+	sort.Strings(pairs)
+
+	return strings.Replace(strings.Join(pairs, "&"), "+", "%20", -1)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
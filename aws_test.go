@@ -0,0 +1,277 @@
+package env
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// the example credentials of the Signature Version 4 test suite
+var testAWSCredentials = AWSCredentials{
+	AccessKeyID:     "AKIDEXAMPLE",
+	SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+}
+
+func TestSignAWS(t *testing.T) {
+	// requests and signatures from the Signature Version 4 test suite
+	tests := []struct {
+		name      string
+		method    string
+		url       string
+		signature string
+	}{
+		{"get-vanilla", http.MethodGet, "https://example.amazonaws.com/", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"get-vanilla-query-order-key-case", http.MethodGet, "https://example.amazonaws.com/?Param2=value2&Param1=value1", "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+		{"post-vanilla", http.MethodPost, "https://example.amazonaws.com/", "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b"},
+	}
+
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			signAWS(req, nil, testAWSCredentials, "us-east-1", "service", now)
+
+			want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=" + tt.signature
+			if got := req.Header.Get("Authorization"); got != want {
+				t.Errorf("Authorization = %q\nwant %q", got, want)
+			}
+		})
+	}
+}
+
+func TestSignAWSSessionToken(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	creds := testAWSCredentials
+	creds.SessionToken = "token"
+	signAWS(req, nil, creds, "us-east-1", "service", time.Now())
+
+	if got := req.Header.Get("X-Amz-Security-Token"); got != "token" {
+		t.Errorf("X-Amz-Security-Token = %q, want token", got)
+	}
+	if got := req.Header.Get("Authorization"); !strings.Contains(got, "SignedHeaders=host;x-amz-date;x-amz-security-token,") {
+		t.Errorf("the session token is not signed: %s", got)
+	}
+}
+
+func TestCanonicalQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", ""},
+		{"b=2&a=1", "a=1&b=2"},
+		{"a=2&a=1", "a=1&a=2"},
+		{"a=x y", "a=x%20y"},
+		{"a=%2F", "a=%2F"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/?"+tt.query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := canonicalQuery(req.URL.Query()); got != tt.want {
+				t.Errorf("canonicalQuery(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSSMParameters(t *testing.T) {
+	pages := []string{
+		`{"Parameters": [{"Name": "/app/prod/db-url", "Value": "postgres://db"}], "NextToken": "next"}`,
+		`{"Parameters": [{"Name": "/app/prod/db/password", "Value": "secret"}]}`,
+	}
+
+	var requests []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Amz-Target"); got != "AmazonSSM.GetParametersByPath" {
+			t.Errorf("X-Amz-Target = %q", got)
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") {
+			t.Errorf("the request is not signed: %s", r.Header.Get("Authorization"))
+		}
+
+		var req map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&req)
+		requests = append(requests, req)
+
+		w.Write([]byte(pages[len(requests)-1]))
+	}))
+	defer srv.Close()
+
+	a := SSMParameters(SSMOptions{
+		AWSOptions: AWSOptions{
+			Region:      "us-east-1",
+			Endpoint:    srv.URL,
+			Credentials: func() (AWSCredentials, error) { return testAWSCredentials, nil },
+		},
+		Path:      "/app/prod/",
+		Recursive: true,
+	})
+
+	emap, err := a.pull(Environ())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := EnvMap{"DB_URL": "postgres://db", "DB_PASSWORD": "secret"}
+	if !reflect.DeepEqual(emap.Map, want) {
+		t.Errorf("pulled %q, want %q", emap.Map, want)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("made %d requests, want 2", len(requests))
+	}
+	if requests[0]["NextToken"] != nil || requests[1]["NextToken"] != "next" {
+		t.Errorf("the pages were not asked for with the token: %v", requests)
+	}
+	if requests[0]["MaxResults"] != float64(10) || requests[0]["WithDecryption"] != true {
+		t.Errorf("unexpected request %v", requests[0])
+	}
+}
+
+func TestSecretsManagerSecrets(t *testing.T) {
+	var batches [][]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Amz-Target"); got != "secretsmanager.BatchGetSecretValue" {
+			t.Errorf("X-Amz-Target = %q", got)
+		}
+
+		var req struct{ SecretIdList []interface{} }
+		json.NewDecoder(r.Body).Decode(&req)
+		batches = append(batches, req.SecretIdList)
+
+		// a secret stored as binary has no SecretString and is not exported
+		var values []string
+		for _, id := range req.SecretIdList {
+			if id == "prod/cert" {
+				values = append(values, `{"Name": "prod/cert", "SecretBinary": "AAE="}`)
+			} else {
+				values = append(values, `{"Name": "`+id.(string)+`", "SecretString": "value of `+id.(string)+`"}`)
+			}
+		}
+		w.Write([]byte(`{"SecretValues": [` + strings.Join(values, ",") + `]}`))
+	}))
+	defer srv.Close()
+
+	a := SecretsManagerSecrets(SecretsManagerOptions{
+		AWSOptions: AWSOptions{
+			Region:      "us-east-1",
+			Endpoint:    srv.URL,
+			Credentials: func() (AWSCredentials, error) { return testAWSCredentials, nil },
+		},
+		Names:    []string{"prod/db-password", "prod/api-key", "prod/cert"},
+		Prefix:   "prod/",
+		PageSize: 2,
+	})
+
+	emap, err := a.pull(Environ())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := EnvMap{"DB_PASSWORD": "value of prod/db-password", "API_KEY": "value of prod/api-key"}
+	if !reflect.DeepEqual(emap.Map, want) {
+		t.Errorf("pulled %q, want %q", emap.Map, want)
+	}
+
+	// the names are sent a page at a time
+	if len(batches) != 2 || len(batches[0]) != 2 || len(batches[1]) != 1 {
+		t.Errorf("asked for the secrets in batches %v, want 2 and then 1", batches)
+	}
+}
+
+func TestAWSCredentialsFromEarlierAdapter(t *testing.T) {
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.Write([]byte(`{"Parameters": []}`))
+	}))
+	defer srv.Close()
+
+	// like aws-web-identity, an adapter before SSM exports the credentials it signs with
+	l := NewLoader()
+	l.ApplyAdapter(&Adapter{
+		Name: "credentials",
+		Pull: func() (*Map, error) {
+			emap := NewMap()
+			emap.Set("AWS_ACCESS_KEY_ID", "LOADEDKEY")
+			emap.Set("AWS_SECRET_ACCESS_KEY", "secret")
+			emap.Set("AWS_REGION", "eu-west-1")
+			return emap, nil
+		},
+	})
+	l.ApplyAdapter(SSMParameters(SSMOptions{AWSOptions: AWSOptions{Endpoint: srv.URL}, Path: "/app/"}))
+
+	_, err := l.pull(NewMap())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=LOADEDKEY/") || !strings.Contains(auth, "/eu-west-1/ssm/") {
+		t.Errorf("SSM did not sign with the loaded credentials: %s", auth)
+	}
+}
+
+func TestSSMParametersPush(t *testing.T) {
+	var puts []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch target := r.Header.Get("X-Amz-Target"); target {
+		case "AmazonSSM.GetParametersByPath":
+			w.Write([]byte(`{"Parameters": [
+				{"Name": "/app/prod/db-password", "Type": "SecureString", "Value": "old"},
+				{"Name": "/app/prod/port", "Type": "String", "Value": "80"}
+			]}`))
+		case "AmazonSSM.PutParameter":
+			var req map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&req)
+			puts = append(puts, req)
+			w.Write([]byte(`{"Version": 2}`))
+		default:
+			t.Errorf("unexpected X-Amz-Target %q", target)
+		}
+	}))
+	defer srv.Close()
+
+	a := SSMParameters(SSMOptions{
+		AWSOptions: AWSOptions{
+			Region:      "us-east-1",
+			Endpoint:    srv.URL,
+			Credentials: func() (AWSCredentials, error) { return testAWSCredentials, nil },
+		},
+		Path: "/app/prod",
+	})
+
+	l := NewLoader()
+	l.ApplyAdapter(a)
+
+	err := l.Push("aws-ssm", Parse("DB_PASSWORD=new\nPORT=80\nAPI_TOKEN=t\nHOST=0.0.0.0"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []map[string]interface{}{
+		{"Name": "/app/prod/API_TOKEN", "Value": "t", "Type": "SecureString", "Overwrite": true},
+		{"Name": "/app/prod/db-password", "Value": "new", "Type": "SecureString", "Overwrite": true},
+		{"Name": "/app/prod/HOST", "Value": "0.0.0.0", "Type": "String", "Overwrite": true},
+	}
+	if !reflect.DeepEqual(puts, want) {
+		t.Errorf("put %v, want %v", puts, want)
+	}
+}
@@ -0,0 +1,82 @@
+package env
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// generatedEnv is an env file with n keys, like the generated files with tens of thousands of entries
+func generatedEnv(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "# the value of key %d\nBENCH_KEY_%d=\"value %d\"\n", i, i, i)
+	}
+
+	return b.String()
+}
+
+func BenchmarkParse(b *testing.B) {
+	for _, n := range []int{100, 10000, 50000} {
+		content := generatedEnv(n)
+
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(content)))
+
+			for i := 0; i < b.N; i++ {
+				ParseReader(strings.NewReader(content))
+			}
+		})
+	}
+}
+
+func BenchmarkParseUnclosedQuote(b *testing.B) {
+	content := "BROKEN=\"never closed\n" + generatedEnv(10000)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(content)))
+
+	for i := 0; i < b.N; i++ {
+		ParseReader(strings.NewReader(content))
+	}
+}
+
+func BenchmarkRead(b *testing.B) {
+	for _, n := range []int{100, 10000} {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			path := writeEnvFile(b, generatedEnv(n))
+			l := NewLoader(WithExpand())
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				_, err := l.Read(path)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkLoad(b *testing.B) {
+	const n = 1000
+	path := writeEnvFile(b, generatedEnv(n))
+	defer func() {
+		for i := 0; i < n; i++ {
+			os.Unsetenv(fmt.Sprintf("BENCH_KEY_%d", i))
+		}
+	}()
+
+	l := NewLoader()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		err := l.Load(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package env
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServiceClient reads the env from a Server, see FromService for an adapter
+type ServiceClient struct {
+	addr   string
+	token  string
+	base   string
+	client *http.Client
+}
+
+/*
+NewServiceClient creates a client for the Server listening on addr, which is `unix:///path/to.sock`
+or a host:port. token is the token the Server was started with
+*/
+func NewServiceClient(addr, token string) *ServiceClient {
+	c := &ServiceClient{addr: addr, token: token, base: "http://" + addr}
+	transport := &http.Transport{}
+
+	if strings.HasPrefix(addr, "unix://") {
+		path := strings.TrimPrefix(addr, "unix://")
+
+		c.base = "http://env"
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		}
+	}
+
+	// no timeout on the client since watching waits for a change, requests use a context instead
+	c.client = &http.Client{Transport: transport}
+
+	return c
+}
+
+// Get returns the env the Server has and its version
+func (c *ServiceClient) Get(ctx context.Context) (*Map, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	return c.get(ctx, "/v1/env")
+}
+
+/*
+Watch calls onChange with the env every time it changes on the Server, starting with the env it
+has now, until ctx is done or onChange returns an error. When the Server can not be reached Watch
+tries again every few seconds, keeping the last env
+*/
+func (c *ServiceClient) Watch(ctx context.Context, onChange func(emap *Map) error) error {
+	version := 0
+
+	for {
+		reqCtx, cancel := context.WithTimeout(ctx, maxWait+10*time.Second)
+		emap, current, err := c.get(reqCtx, "/v1/env?wait="+strconv.Itoa(version))
+		cancel()
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(2 * time.Second):
+			}
+			continue
+		}
+
+		if current == version {
+			continue
+		}
+		version = current
+
+		err = onChange(emap)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (c *ServiceClient) get(ctx context.Context, path string) (*Map, int, error) {
+	req, err := http.NewRequest(http.MethodGet, c.base+path, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not reach the env service at %s: %s", c.addr, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, 0, fmt.Errorf("env service at %s: %s %s", c.addr, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var body serverResponse
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not parse the response of the env service at %s: %s", c.addr, err)
+	}
+
+	emap := NewMap()
+	for key, val := range body.Env {
+		emap.Set(key, val)
+	}
+
+	return emap, body.Version, nil
+}
+
+/*
+FromService returns an adapter that pulls the env from the Server at addr, using the token in
+ENV_SERVE_TOKEN, which can come from a file loaded before the adapter runs. It pulls once per load,
+use ServiceClient.Watch to follow the changes
+*/
+func FromService(addr string) *Adapter {
+	// every pull shares the client so its connections are reused, with the token of that load
+	shared := NewServiceClient(addr, "")
+
+	pull := func(loaded Reader) (*Map, error) {
+		client := *shared
+		client.token = getenv(loaded, "ENV_SERVE_TOKEN")
+
+		emap, _, err := client.Get(context.Background())
+
+		return emap, err
+	}
+
+	return loadedAdapter("env-service", true, pull)
+}
@@ -0,0 +1,36 @@
+package env
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFromServiceTokenFromFile(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fromfile" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"version": 1, "env": {"SERVICE_KEY": "value"}}`))
+	}))
+	defer srv.Close()
+
+	defer os.Unsetenv("SERVICE_KEY")
+	defer os.Unsetenv("ENV_SERVE_TOKEN")
+
+	// the token is only in the file, it is not in the env until the load is done
+	l := NewLoader()
+	l.ApplyAdapter(FromService(strings.TrimPrefix(srv.URL, "http://")))
+
+	err := l.Load(writeEnvFile(t, "ENV_SERVE_TOKEN=fromfile\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := os.Getenv("SERVICE_KEY"); got != "value" {
+		t.Errorf("SERVICE_KEY = %q, want value", got)
+	}
+}
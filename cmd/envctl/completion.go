@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+)
+
+func newCompletionCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "completion bash|zsh|fish",
+		Short: "print the shell completion script for bash, zsh or fish",
+		Long: "completion prints the completion script of the shell, it completes the commands, their flags and\n" +
+			"the files the flags take\n\n" +
+			"  bash: source <(envctl completion bash)\n" +
+			"  zsh:  envctl completion zsh > \"${fpath[1]}/_envctl\"\n" +
+			"  fish: envctl completion fish > ~/.config/fish/completions/envctl.fish",
+		ValidArgs: []string{"bash", "zsh", "fish"},
+		Args:      usageArgs(cobra.ExactValidArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if jsonOutput {
+				var script bytes.Buffer
+				err := completion(cmd.Root(), &script, args[0])
+				if err != nil {
+					return err
+				}
+
+				return writeJSON(os.Stdout, map[string]string{"shell": args[0], "script": script.String()})
+			}
+
+			return completion(cmd.Root(), os.Stdout, args[0])
+		},
+	}
+}
+
+func newManCommand() *cobra.Command {
+	var dir string
+
+	cmd := &cobra.Command{
+		Use:   "man [--dir dir]",
+		Short: "print the man page of the CLI, or write one for every command",
+		Long: "man prints the man page of envctl, which lists the commands. With --dir it writes a page for every\n" +
+			"command to the directory instead, like envctl-show.1 and envctl-schema-validate.1",
+		Args: usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := cmd.Root()
+			header := manHeader(time.Now())
+
+			if dir != "" {
+				return doc.GenManTreeFromOpts(root, doc.GenManTreeOptions{Header: header, Path: dir, CommandSeparator: "-"})
+			}
+
+			if jsonOutput {
+				pages := make(map[string]string)
+				err := manPages(root, header, pages)
+				if err != nil {
+					return err
+				}
+
+				return writeJSON(os.Stdout, pages)
+			}
+
+			return doc.GenMan(root, header, os.Stdout)
+		},
+	}
+	cmd.Flags().StringVar(&dir, "dir", "", "write a page for every command to this directory, like /usr/local/share/man/man1")
+	cmd.MarkFlagDirname("dir")
+
+	return cmd
+}
+
+// completion writes the completion script of the shell, cobra generates it from the commands and their flags
+func completion(root *cobra.Command, w io.Writer, shell string) error {
+	switch shell {
+	case "bash":
+		return root.GenBashCompletionV2(w, true)
+	case "zsh":
+		return root.GenZshCompletion(w)
+	case "fish":
+		return root.GenFishCompletion(w, true)
+	}
+
+	return &usageError{fmt.Sprintf("unknown shell %q, expected bash, zsh or fish", shell)}
+}
+
+// manHeader is the header of every man page, doc titles every page after its command
+func manHeader(now time.Time) *doc.GenManHeader {
+	return &doc.GenManHeader{Section: "1", Source: "envctl", Manual: "User Commands", Date: &now}
+}
+
+// manPages adds the page of the command and of every command under it to pages, by the name of the page
+func manPages(cmd *cobra.Command, header *doc.GenManHeader, pages map[string]string) error {
+	if !cmd.IsAvailableCommand() && cmd != cmd.Root() {
+		return nil
+	}
+
+	// GenMan sets the title of the header it is given, so every page gets its own copy
+	h := *header
+
+	var page bytes.Buffer
+	err := doc.GenMan(cmd, &h, &page)
+	if err != nil {
+		return err
+	}
+	pages[strings.Replace(cmd.CommandPath(), " ", "-", -1)+"."+header.Section] = page.String()
+
+	for _, sub := range cmd.Commands() {
+		err = manPages(sub, header, pages)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,9 @@
+module github.com/andreGarvin/env/cmd/envctl
+
+go 1.15
+
+require (
+	github.com/andreGarvin/env v0.0.0-00010101000000-000000000000
+	github.com/spf13/cobra v1.8.1
+	github.com/spf13/pflag v1.0.5
+)
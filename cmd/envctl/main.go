@@ -0,0 +1,264 @@
+/*
+Command envctl works with env files from the shell
+
+	envctl show [flags]                      print the merged env as a table, with secrets masked
+	envctl schema version|validate|migrate   work with the schema file
+	envctl serve [flags]                     serve the merged env over HTTP on localhost or a unix socket
+	envctl public [flags]                    print the keys with a public prefix, like VITE_, for a frontend build
+	envctl push <adapter> [flags]            push the keys of the env files with an adapter of the manifest, after a diff
+	envctl completion bash|zsh|fish          print the shell completion script
+	envctl man [--dir dir]                   print the man page, or write one for every command
+
+The commands are built with cobra, run `envctl <command> --help` for the flags of a command. Every
+command takes --json to print its result as JSON instead, errors are then written to stderr as
+{"error": "...", "kind": "...", "code": n}.
+
+The exit code tells scripts what went wrong
+
+	0  success
+	1  any other error
+	2  bad usage, like an unknown command or flag
+	3  an env or schema file could not be read or parsed (including expanding it), or the schema is in an unsupported version
+	4  validation failed, like a required key that is missing or a value that does not match the schema
+	5  an adapter failed
+*/
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/andreGarvin/env"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// the exit codes of the CLI, see the package doc
+const (
+	exitError      = 1
+	exitUsage      = 2
+	exitParse      = 3
+	exitValidation = 4
+	exitAdapter    = 5
+)
+
+// jsonOutput is set by the --json flag every command has
+var jsonOutput bool
+
+// exitCodes is the end of the root help and the man page
+const exitCodes = `The exit code tells scripts what went wrong
+
+  0  success
+  1  any other error
+  2  bad usage, like an unknown command or flag
+  3  an env or schema file could not be read or parsed, or the schema is in an unsupported version
+  4  validation failed, like a required key that is missing or a value that does not match the schema
+  5  an adapter failed`
+
+// newRootCommand creates the envctl command with every subcommand
+func newRootCommand() *cobra.Command {
+	root := &cobra.Command{
+		Use:   "envctl",
+		Short: "work with env files from the shell",
+		Long: "envctl loads env files the same way the github.com/andreGarvin/env package does, so what it prints\n" +
+			"is what an app using the package runs with.\n\n" +
+			"Every command takes --json to print its result as JSON instead, errors are then written to stderr\n" +
+			"as {\"error\": \"...\", \"kind\": \"...\", \"code\": n}.\n\n" + exitCodes,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.Usage()
+			return &usageError{"expected a command"}
+		},
+
+		// errors are printed by main, with the exit code they map to
+		SilenceErrors: true,
+		SilenceUsage:  true,
+
+		// completion is its own command so it only offers the shells below
+		CompletionOptions: cobra.CompletionOptions{DisableDefaultCmd: true},
+		DisableAutoGenTag: true,
+	}
+
+	root.PersistentFlags().BoolVar(&jsonOutput, "json", false, "print the result as JSON")
+	root.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		cmd.Usage()
+		return &usageError{err.Error()}
+	})
+
+	root.AddCommand(
+		newShowCommand(),
+		newSchemaCommand(),
+		newServeCommand(),
+		newPublicCommand(),
+		newPushCommand(),
+		newCompletionCommand(),
+		newManCommand(),
+	)
+
+	return root
+}
+
+func main() {
+	root := newRootCommand()
+
+	cmd, err := root.ExecuteC()
+	if err != nil {
+		// cobra's own errors, like an unknown command, come back from the root
+		var usageErr *usageError
+		if cmd == root && !errors.As(err, &usageErr) {
+			err = &usageError{err.Error()}
+		}
+
+		kind, code := classify(err)
+
+		if jsonOutput {
+			writeJSON(os.Stderr, map[string]interface{}{"error": err.Error(), "kind": kind, "code": code})
+		} else {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.CommandPath(), err)
+		}
+		os.Exit(code)
+	}
+}
+
+// usageError is returned by commands that were called with the wrong arguments
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+// usageArgs makes the errors of a cobra argument check usage errors
+func usageArgs(check cobra.PositionalArgs) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		err := check(cmd, args)
+		if err != nil {
+			cmd.Usage()
+			return &usageError{err.Error()}
+		}
+
+		return nil
+	}
+}
+
+// classify returns the kind and exit code of the error
+func classify(err error) (string, int) {
+	var (
+		usageErr     *usageError
+		fileErr      *env.FileError
+		envErr       *env.EnvironmentError
+		expandErr    *env.ExpandError
+		undefinedErr *env.UndefinedError
+		sizeErr      *env.SizeError
+		adapterErr   *env.AdapterError
+		versionErr   *env.SchemaVersionError
+		schemaErr    *env.SchemaError
+	)
+
+	switch {
+	case errors.As(err, &usageErr):
+		return "usage", exitUsage
+	case errors.As(err, &adapterErr):
+		return "adapter", exitAdapter
+	case errors.As(err, &fileErr), errors.As(err, &envErr), errors.As(err, &expandErr), errors.As(err, &undefinedErr), errors.As(err, &versionErr):
+		return "parse", exitParse
+	case errors.Is(err, errInvalid), errors.As(err, &sizeErr), errors.As(err, &schemaErr):
+		return "validation", exitValidation
+	}
+
+	return "error", exitError
+}
+
+// writeJSON writes v as indented JSON
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
+
+// loadFlags are the flags every command that loads env files has
+type loadFlags struct {
+	files    listFlag
+	require  listFlag
+	expand   bool
+	local    bool
+	manifest string
+}
+
+func (lf *loadFlags) register(cmd *cobra.Command) {
+	fs := cmd.Flags()
+	fs.VarP(&lf.files, "file", "f", "env file to load, can be given more than once (default .env)")
+	fs.Var(&lf.require, "require", "comma separated keys that must be set, can be given more than once")
+	fs.BoolVar(&lf.expand, "expand", false, "expand ${VAR} references")
+	fs.BoolVar(&lf.local, "local", false, "also load the <name>.local file of every file")
+	fs.StringVar(&lf.manifest, "manifest", "", "load the way the manifest file describes, the other flags are applied on top")
+
+	cmd.MarkFlagFilename("file")
+	cmd.MarkFlagFilename("manifest", "yaml", "yml", "toml", "json")
+}
+
+// loader creates the Loader the flags describe, files that are skipped are reported on stderr
+func (lf *loadFlags) loader() (*env.Loader, error) {
+	opts := lf.options()
+
+	var l *env.Loader
+	if lf.manifest != "" {
+		var err error
+		l, err = env.LoadManifest(lf.manifest, opts...)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		l = env.NewLoader(opts...)
+	}
+	l.RequiredKeys(lf.require)
+
+	return l, nil
+}
+
+// options are the options of the flags, without the manifest
+func (lf *loadFlags) options() []env.Option {
+	opts := []env.Option{env.WithLogger(func(format string, args ...interface{}) {
+		fmt.Fprintf(os.Stderr, "envctl: "+format+"\n", args...)
+	})}
+
+	if len(lf.files) != 0 {
+		opts = append(opts, env.WithFiles(lf.files...))
+	}
+	if lf.expand {
+		opts = append(opts, env.WithExpand())
+	}
+	if lf.local {
+		opts = append(opts, env.WithLocalFiles())
+	}
+
+	return opts
+}
+
+// listFlag is a flag that can be given more than once, and takes comma separated values
+type listFlag []string
+
+var _ pflag.Value = (*listFlag)(nil)
+
+func (f *listFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *listFlag) Set(val string) error {
+	for _, item := range strings.Split(val, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*f = append(*f, item)
+		}
+	}
+
+	return nil
+}
+
+func (f *listFlag) Type() string {
+	return "list"
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/andreGarvin/env"
+	"github.com/spf13/cobra"
+)
+
+func newPublicCommand() *cobra.Command {
+	var lf loadFlags
+	var format string
+	var header bool
+	var prefixes listFlag
+
+	cmd := &cobra.Command{
+		Use:   "public [flags]",
+		Short: "print the keys with a public prefix, like VITE_, for a frontend build",
+		Long: "public prints only the keys with a public prefix (VITE_, NEXT_PUBLIC_, REACT_APP_ and the like, or\n" +
+			"--prefix), so the client of a full stack app is built from the same .env without the server's secrets",
+		Args: usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if jsonOutput {
+				format = string(env.OutputJSON)
+			}
+
+			return public(os.Stdout, &lf, env.OutputFormat(format), header, prefixes)
+		},
+	}
+
+	lf.register(cmd)
+	cmd.Flags().StringVar(&format, "format", "json", "json, js for a module with a default export, define for the define option of a bundler, or env")
+	cmd.Flags().BoolVar(&header, "header", false, "start the output with a comment naming the sources and a hash of the content, for js and env")
+	cmd.Flags().Var(&prefixes, "prefix", "comma separated prefixes of the public keys, can be given more than once (default VITE_, NEXT_PUBLIC_, REACT_APP_...)")
+	cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"json", "js", "define", "env"}, cobra.ShellCompDirectiveNoFileComp))
+
+	return cmd
+}
+
+func public(w io.Writer, lf *loadFlags, format env.OutputFormat, header bool, prefixes []string) error {
+	switch format {
+	case env.OutputJSON, env.OutputModule, env.OutputDefine, env.OutputEnv:
+	default:
+		return &usageError{fmt.Sprintf("unknown format %q, expected json, js, define or env", format)}
+	}
+	if header && format != env.OutputModule && format != env.OutputEnv {
+		return &usageError{fmt.Sprintf("--header needs --format js or env, %s has no comments", format)}
+	}
+
+	l, err := lf.loader()
+	if err != nil {
+		return err
+	}
+
+	emap, err := l.Read()
+	if err != nil {
+		return err
+	}
+
+	keys := emap.Public(prefixes...)
+
+	// a public prefix on a secret is almost always a mistake, the value ends up in the bundle
+	for _, key := range keys.Keys() {
+		if keys.IsSecret(key) {
+			fmt.Fprintf(os.Stderr, "envctl: warning: %s looks like a secret, it will be readable by anyone who loads the page\n", key)
+		}
+	}
+
+	if header {
+		return keys.WriteGenerated(w, format)
+	}
+
+	return keys.WriteFormat(w, format)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/andreGarvin/env"
+	"github.com/spf13/cobra"
+)
+
+func newPushCommand() *cobra.Command {
+	var lf loadFlags
+	var yes, dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "push <adapter> [flags]",
+		Short: "push the keys of the env files with an adapter of the manifest, after a diff",
+		Long: "push sets the keys of the env files where the adapter of the manifest pulls them from. Only the keys\n" +
+			"that would change are pushed, and their diff is shown with secrets masked before anything is\n" +
+			"written. Without --yes it asks first, on a terminal, and nothing is pushed unless the answer is yes",
+		Args: usageArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if lf.manifest == "" {
+				return &usageError{"push needs --manifest to know the adapter"}
+			}
+
+			return push(os.Stdout, &lf, args[0], yes, dryRun)
+		},
+	}
+
+	lf.register(cmd)
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "push without asking, the diff is still printed")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "only print the diff, push nothing")
+
+	return cmd
+}
+
+// change is a line of the push diff, secret values are masked
+type change struct {
+	Key   string `json:"key"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new"`
+	Added bool   `json:"added"`
+}
+
+func push(w io.Writer, lf *loadFlags, adapter string, yes, dryRun bool) error {
+	l, err := lf.loader()
+	if err != nil {
+		return err
+	}
+
+	// only the keys of the files are pushed, not the ones the adapters pulled on top of them
+	emap, err := env.NewLoader(lf.options()...).Read()
+	if err != nil {
+		return err
+	}
+
+	// the prompt goes to stderr so the diff on stdout stays readable by scripts
+	prompt := env.ConfirmPrompt(os.Stdin, os.Stderr)
+
+	var changes []env.Change
+	err = l.Push(adapter, emap, func(diff []env.Change) bool {
+		changes = diff
+
+		if !jsonOutput && (yes || dryRun) {
+			for _, c := range diff {
+				fmt.Fprintln(w, c)
+			}
+		}
+
+		switch {
+		case dryRun:
+			return false
+		case yes:
+			return true
+		}
+
+		return prompt(diff)
+	})
+
+	pushed := err == nil && len(changes) != 0
+	if dryRun && errors.Is(err, env.ErrPushCancelled) {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		rows := []change{}
+		for _, c := range changes {
+			r := change{Key: c.Key, Old: c.Old, New: c.New, Added: c.Added}
+			if c.Secret {
+				r.New = env.RedactedValue
+				if !c.Added {
+					r.Old = env.RedactedValue
+				}
+			}
+			rows = append(rows, r)
+		}
+
+		return writeJSON(w, map[string]interface{}{"adapter": adapter, "changes": rows, "pushed": pushed})
+	}
+
+	switch {
+	case len(changes) == 0:
+		fmt.Fprintf(w, "%s is up to date\n", adapter)
+	case pushed:
+		fmt.Fprintf(w, "pushed %d changes to %s\n", len(changes), adapter)
+	}
+
+	return nil
+}
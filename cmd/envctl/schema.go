@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/andreGarvin/env"
+	"github.com/spf13/cobra"
+)
+
+func newSchemaCommand() *cobra.Command {
+	var path string
+
+	cmd := &cobra.Command{
+		Use:   "schema version|validate|migrate [flags]",
+		Short: "print the version of, validate against or migrate a schema file",
+		Args:  usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.Usage()
+			return &usageError{"expected version, validate or migrate"}
+		},
+	}
+	cmd.PersistentFlags().StringVar(&path, "schema", "env.schema.json", "the schema file")
+	cmd.MarkPersistentFlagFilename("schema", "json")
+
+	version := &cobra.Command{
+		Use:   "version",
+		Short: "print the version of the schema and the version envctl supports",
+		Args:  usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return schemaVersion(path)
+		},
+	}
+
+	var lf loadFlags
+	validate := &cobra.Command{
+		Use:   "validate [flags]",
+		Short: "load the env files and check them against the schema",
+		Args:  usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return schemaValidate(path, &lf)
+		},
+	}
+	lf.register(validate)
+
+	var write bool
+	migrate := &cobra.Command{
+		Use:   "migrate [flags]",
+		Short: "upgrade the schema to the supported version",
+		Args:  usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return schemaMigrate(path, write)
+		},
+	}
+	migrate.Flags().BoolVarP(&write, "write", "w", false, "write the migrated schema back to the file instead of printing it")
+
+	cmd.AddCommand(version, validate, migrate)
+
+	return cmd
+}
+
+func schemaVersion(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	_, err = env.ParseSchema(data)
+	version, supported := env.SchemaVersion, env.SchemaVersion
+
+	var verr *env.SchemaVersionError
+	if errors.As(err, &verr) {
+		version = verr.Version
+	} else if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		werr := writeJSON(os.Stdout, map[string]interface{}{"version": version, "supported": supported, "compatible": version == supported})
+		if werr != nil {
+			return werr
+		}
+	} else {
+		fmt.Printf("schema version %d, supported version %d\n", version, supported)
+	}
+
+	// an incompatible schema still fails so scripts can check the exit code
+	return err
+}
+
+func schemaValidate(path string, lf *loadFlags) error {
+	schema, err := env.LoadSchema(path)
+	if err != nil {
+		return err
+	}
+
+	l, err := lf.loader()
+	if err != nil {
+		return err
+	}
+
+	emap, err := l.Read()
+	if err != nil {
+		return err
+	}
+
+	// keys that are not in the files can still be set in the env
+	defaults := schema.Defaults(emap)
+	r := env.Layered(emap, defaults, env.Environ())
+	err = schema.Validate(r)
+	deprecations := schema.Deprecations(r)
+	suspicious := env.Suspicious(emap)
+
+	if jsonOutput {
+		problems := []env.Problem{}
+
+		var serr *env.SchemaError
+		if errors.As(err, &serr) {
+			problems = serr.Problems
+		}
+
+		if deprecations == nil {
+			deprecations = []env.Problem{}
+		}
+		if suspicious == nil {
+			suspicious = []env.Problem{}
+		}
+
+		werr := writeJSON(os.Stdout, map[string]interface{}{"valid": err == nil, "problems": problems, "deprecations": deprecations, "warnings": suspicious})
+		if werr != nil {
+			return werr
+		}
+	} else {
+		for _, d := range append(deprecations, suspicious...) {
+			fmt.Fprintf(os.Stderr, "envctl: warning: %s\n", d)
+		}
+
+		if err == nil {
+			fmt.Println("ok")
+		}
+	}
+
+	return err
+}
+
+func schemaMigrate(path string, write bool) error {
+	if write {
+		return env.MigrateSchemaFile(path)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	migrated, err := env.MigrateSchema(data)
+	if err != nil {
+		return err
+	}
+
+	// the schema is JSON already, so --json does not change the output
+	_, err = os.Stdout.Write(migrated)
+	return err
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/andreGarvin/env"
+	"github.com/spf13/cobra"
+)
+
+func newServeCommand() *cobra.Command {
+	var lf loadFlags
+	var addr, tokenFile string
+	var interval, jitter time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "serve [flags]",
+		Short: "serve the merged env over HTTP on localhost or a unix socket",
+		Long: "serve serves the merged env over HTTP so services in other languages on the same host can use the\n" +
+			"same files and adapters. It only listens on a loopback address or a unix socket, reads the env again\n" +
+			"every --interval and every request needs the token from $ENV_SERVE_TOKEN (or --token-file)",
+		Args: usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			token := os.Getenv("ENV_SERVE_TOKEN")
+			if tokenFile != "" {
+				bytes, err := ioutil.ReadFile(tokenFile)
+				if err != nil {
+					return err
+				}
+				token = strings.TrimSpace(string(bytes))
+			}
+			if token == "" {
+				return &usageError{"no token, set ENV_SERVE_TOKEN or pass --token-file"}
+			}
+
+			l, err := lf.loader()
+			if err != nil {
+				return err
+			}
+
+			srv := env.NewServer(l, token)
+			srv.Interval = interval
+			srv.Jitter = jitter
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			signals := make(chan os.Signal, 1)
+			signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+			go func() {
+				<-signals
+				cancel()
+			}()
+
+			if !jsonOutput {
+				fmt.Fprintf(os.Stderr, "envctl: serving on %s\n", addr)
+			}
+
+			return srv.ListenAndServe(ctx, addr)
+		},
+	}
+
+	lf.register(cmd)
+	cmd.Flags().StringVar(&addr, "addr", "127.0.0.1:7070", "loopback host:port or unix:///path/to.sock to listen on")
+	cmd.Flags().StringVar(&tokenFile, "token-file", "", "file holding the token clients must send, by default $ENV_SERVE_TOKEN")
+	cmd.Flags().DurationVar(&interval, "interval", 30*time.Second, "how often the env is read again")
+	cmd.Flags().DurationVar(&jitter, "jitter", 0, "wait up to this much longer than --interval, at random, so servers started together do not read at the same time")
+	cmd.MarkFlagFilename("token-file")
+
+	return cmd
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/andreGarvin/env"
+	"github.com/spf13/cobra"
+)
+
+func newShowCommand() *cobra.Command {
+	var lf loadFlags
+	var reveal bool
+	var secrets listFlag
+
+	cmd := &cobra.Command{
+		Use:   "show [flags]",
+		Short: "print the merged env as a table, with secrets masked",
+		Long: "show prints the env the app would run with, every key with its value, the file or adapter it came\n" +
+			"from and if it is valid. Keys tagged secret or with names like *_PASSWORD and *_TOKEN are masked\n" +
+			"unless --reveal is given, a required key that is missing fails with exit code 4",
+		Args: usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return show(os.Stdout, &lf, reveal, secrets)
+		},
+	}
+
+	lf.register(cmd)
+	cmd.Flags().BoolVar(&reveal, "reveal", false, "print secret values instead of masking them")
+	cmd.Flags().Var(&secrets, "secret", "comma separated keys to mask on top of the ones that look secret, can be given more than once")
+
+	return cmd
+}
+
+// errInvalid is returned by show once the table is printed when a required key is missing
+var errInvalid = errors.New("required keys are missing")
+
+// row is a line of the show table
+type row struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+	Status string `json:"status"`
+}
+
+func show(w io.Writer, lf *loadFlags, reveal bool, secrets []string) error {
+	l, err := lf.loader()
+	if err != nil {
+		return err
+	}
+
+	emap, err := l.Read()
+	if err != nil {
+		return err
+	}
+
+	rows := showRows(emap, lf.require, func(key string) bool {
+		return !reveal && isSecret(emap, secrets, key)
+	})
+
+	valid := true
+	for _, r := range rows {
+		if r.Status != "ok" {
+			valid = false
+		}
+	}
+
+	files := l.LoadedFiles()
+	if files == nil {
+		files = []string{}
+	}
+
+	if jsonOutput {
+		err = writeJSON(w, map[string]interface{}{"files": files, "keys": rows, "valid": valid})
+		if err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintf(w, "files: %s\n\n", strings.Join(files, ", "))
+
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE\tSTATUS")
+		for _, r := range rows {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Key, r.Value, r.Source, r.Status)
+		}
+		tw.Flush()
+	}
+
+	if !valid {
+		return errInvalid
+	}
+
+	return nil
+}
+
+// showRows is every loaded key and then the required keys that were not loaded, which are looked up in the env
+func showRows(emap *env.Map, required []string, mask func(key string) bool) []row {
+	var rows []row
+
+	for _, key := range emap.Keys() {
+		r := row{Key: key, Value: emap.Map[key], Source: emap.Source(key), Status: "ok"}
+		if r.Source == "" {
+			r.Source = "-"
+		}
+		if r.Value == "" && hasKey(required, key) {
+			r.Status = "empty"
+		}
+
+		rows = append(rows, r)
+	}
+
+	for _, key := range required {
+		if _, ok := emap.Map[key]; ok {
+			continue
+		}
+
+		r := row{Key: key, Source: "env", Status: "ok"}
+
+		val, ok := os.LookupEnv(key)
+		switch {
+		case !ok:
+			r.Source, r.Status = "-", "missing"
+		case val == "":
+			r.Status = "empty"
+		}
+		r.Value = val
+
+		rows = append(rows, r)
+	}
+
+	for i := range rows {
+		if rows[i].Value != "" && mask(rows[i].Key) {
+			rows[i].Value = env.RedactedValue
+		}
+	}
+
+	return rows
+}
+
+// isSecret reports if the key is tagged secret, listed with --secret or has a name that looks like a secret
+func isSecret(emap *env.Map, secrets []string, key string) bool {
+	return hasKey(secrets, key) || emap.IsSecret(key)
+}
+
+func hasKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+
+	return false
+}
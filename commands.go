@@ -0,0 +1,193 @@
+package env
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// defaultCommandTimeout is how long a $(command) can run when WithCommands is given no timeout
+const defaultCommandTimeout = 10 * time.Second
+
+// ErrCommandNotAllowed is the Err of a CommandError for a command that is not in the allowlist of WithCommands
+var ErrCommandNotAllowed = errors.New("command is not allowed")
+
+// CommandError is returned when a $(command) in the value of Key can not be run or fails
+type CommandError struct {
+	Key     string
+	Command string
+	Err     error
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("could not run $(%s) for %s: %s", e.Command, e.Key, e.Err)
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// commandSettings are the settings of WithCommands
+type commandSettings struct {
+	timeout time.Duration
+	allowed []string
+}
+
+/*
+substituteCommands replaces the $(command) parts of the values in emap with the output of the
+commands, without their trailing line breaks like a shell. Commands are not run by a shell: the
+command is split into arguments on spaces with quotes grouping them, and the program has to be in
+the allowlist. Single quoted values and $( escaped as \$( are left as they are
+*/
+func substituteCommands(cfg commandSettings, emap *Map) error {
+	for _, key := range emap.Ordered() {
+		val := emap.Map[key]
+		if emap.isLiteral(key) || !strings.Contains(val, "$(") {
+			continue
+		}
+
+		var b strings.Builder
+		for {
+			start := strings.Index(val, "$(")
+			if start == -1 {
+				b.WriteString(val)
+				break
+			}
+			if start > 0 && val[start-1] == '\\' {
+				b.WriteString(val[:start+2])
+				val = val[start+2:]
+				continue
+			}
+
+			end := closingParen(val[start+2:])
+			if end == -1 {
+				return &CommandError{Key: key, Command: val[start+2:], Err: errors.New("missing )")}
+			}
+			command := val[start+2 : start+2+end]
+
+			out, err := runCommand(cfg, command)
+			if err != nil {
+				return &CommandError{Key: key, Command: command, Err: err}
+			}
+
+			b.WriteString(val[:start])
+			b.WriteString(out)
+			val = val[start+2+end+1:]
+		}
+
+		emap.Set(key, b.String())
+	}
+
+	return nil
+}
+
+// closingParen returns the index of the ) closing an opened (, or -1. Parentheses in quotes do not count
+func closingParen(s string) int {
+	depth := 0
+	var quote byte
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+
+	return -1
+}
+
+// runCommand runs the command if its program is allowed and returns its output
+func runCommand(cfg commandSettings, command string) (string, error) {
+	args, err := splitCommand(command)
+	if err != nil {
+		return "", err
+	}
+	if len(args) == 0 {
+		return "", errors.New("command is empty")
+	}
+
+	// the program has to match exactly, so an allowed git does not let /tmp/git run
+	if !hasString(cfg.allowed, args[0]) {
+		return "", ErrCommandNotAllowed
+	}
+
+	timeout := cfg.timeout
+	if timeout <= 0 {
+		timeout = defaultCommandTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("timed out after %s", timeout)
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %s", err, msg)
+		}
+		return "", err
+	}
+
+	return strings.TrimRight(stdout.String(), "\r\n"), nil
+}
+
+// splitCommand splits a command into its arguments on spaces, single and double quotes group an argument
+func splitCommand(command string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	var quote byte
+	inArg := false
+
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				arg.WriteByte(c)
+			}
+		case c == '"' || c == '\'':
+			quote, inArg = c, true
+		case c == ' ' || c == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteByte(c)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+
+	return args, nil
+}
@@ -0,0 +1,107 @@
+package env
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// cronField is the range of values a field of a cron schedule takes, and the names it can use for them
+type cronField struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	// 7 is sunday too
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+// cronMacros are the @ shorthands most cron implementations understand
+var cronMacros = map[string]bool{
+	"@yearly": true, "@annually": true, "@monthly": true, "@weekly": true,
+	"@daily": true, "@midnight": true, "@hourly": true,
+}
+
+/*
+checkCron checks the expression is a standard 5 field cron schedule: minute, hour, day of month,
+month and day of week. Each field is a *, a value, a range like 1-5 or a list of them, with an
+optional /step. Months and days of the week can be named, jan-dec and sun-sat
+*/
+func checkCron(expr string) error {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "@") {
+		if !cronMacros[strings.ToLower(expr)] {
+			return fmt.Errorf("unknown schedule %s", expr)
+		}
+		return nil
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return fmt.Errorf("expected %d fields, got %d", len(cronFields), len(fields))
+	}
+
+	for i, field := range fields {
+		for _, part := range strings.Split(field, ",") {
+			err := cronFields[i].check(part)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: %s", cronFields[i].name, field, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// check checks a single part of a field list, like 1-5/2
+func (f cronField) check(part string) error {
+	if i := strings.Index(part, "/"); i != -1 {
+		step, err := strconv.Atoi(part[i+1:])
+		if err != nil || step <= 0 {
+			return fmt.Errorf("step must be a positive number")
+		}
+		part = part[:i]
+	}
+
+	if part == "*" {
+		return nil
+	}
+
+	bounds := strings.SplitN(part, "-", 2)
+	values := make([]int, len(bounds))
+	for i, bound := range bounds {
+		val, err := f.value(bound)
+		if err != nil {
+			return err
+		}
+		values[i] = val
+	}
+
+	if len(values) == 2 && values[0] > values[1] {
+		return fmt.Errorf("range %s goes backwards", part)
+	}
+
+	return nil
+}
+
+// value parses a number or name of the field
+func (f cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return f.min + i, nil
+		}
+	}
+
+	val, err := strconv.Atoi(s)
+	if err != nil || val < f.min || val > f.max {
+		return 0, fmt.Errorf("%q is not between %d and %d", s, f.min, f.max)
+	}
+
+	return val, nil
+}
@@ -0,0 +1,91 @@
+package env
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// debugInfo is what Handler serves and Debug returns
+type debugInfo struct {
+	// Env is the loaded keys with secret values masked, Sources is where each came from
+	Env      map[string]string `json:"env"`
+	Sources  map[string]string `json:"sources"`
+	Files    []string          `json:"files"`
+	ReadAt   time.Time         `json:"read_at"`
+	Adapters []*adapterPull    `json:"adapters"`
+}
+
+func (l *Loader) debugInfo() debugInfo {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	info := debugInfo{
+		Env:      map[string]string{},
+		Sources:  map[string]string{},
+		Files:    append([]string{}, l.files...),
+		ReadAt:   l.readAt,
+		Adapters: []*adapterPull{},
+	}
+
+	if l.loaded != nil {
+		for key, val := range l.loaded.Map {
+			if val != "" && l.loaded.IsSecret(key) {
+				val = RedactedValue
+			}
+
+			info.Env[key] = val
+			if source := l.loaded.Source(key); source != "" {
+				info.Sources[key] = source
+			}
+		}
+	}
+
+	for _, pull := range l.pulls {
+		p := *pull
+		info.Adapters = append(info.Adapters, &p)
+	}
+	sort.Slice(info.Adapters, func(i, j int) bool {
+		return info.Adapters[i].Name < info.Adapters[j].Name
+	})
+
+	return info
+}
+
+/*
+Handler serves what the Loader loaded as JSON for a debug mux: the keys it set with secret values
+masked and where each came from, the files it read and when, and how the last pull of each adapter went
+
+	mux.Handle("/debug/env", loader.Handler())
+
+It is meant for internal endpoints, the keys themselves and the non secret values are not hidden
+*/
+func (l *Loader) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(l.debugInfo())
+	})
+}
+
+// Debug returns what Handler serves as a value that marshals to the same JSON, for publishing it
+// somewhere else like the envexpvar package does
+func (l *Loader) Debug() interface{} {
+	return l.debugInfo()
+}
+
+// Handler serves what the default Loader loaded, see Loader.Handler
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		getDefaultLoader().Handler().ServeHTTP(w, r)
+	})
+}
+
+// Debug returns what the default Loader loaded, see Loader.Debug
+func Debug() interface{} {
+	return getDefaultLoader().debugInfo()
+}
@@ -0,0 +1,239 @@
+package env
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+Dialect bundles the syntax rules of one flavor of env file. The zero value only understands
+plain KEY=value lines, the presets below match the tools they are named after
+*/
+type Dialect struct {
+	// CommentChars are the characters that start a comment line
+	CommentChars string
+
+	// InlineComments strips comments from the end of values, a comment char only starts one after a space or tab.
+	// Without it a comment char is kept as part of the value
+	InlineComments bool
+
+	// BareComments starts an inline comment at any comment char, even one without a space before it,
+	// so KEY=a#b is a
+	BareComments bool
+
+	// LastComment starts the inline comment at the last comment char after a space instead of the first,
+	// so in `KEY=a # b # c` the value is "a # b"
+	LastComment bool
+
+	// Continuation continues a value ending with a backslash on the next line
+	Continuation bool
+
+	// Quotes removes single or double quotes around values, single quoted values are never expanded
+	Quotes bool
+
+	// Escapes are the chars a backslash escapes in a double quoted value, \n, \r and \t stand for the control
+	// chars and any other is taken as it is. Other backslashes are kept, so \$ still escapes a dollar sign
+	// when expanding. Without Escapes double quoted values are taken as they are
+	Escapes string
+
+	// SplitAfterQuote reads what follows the closing quote of a value as the next line, so KEY="a"B=b sets
+	// both keys. Without it the quotes are part of the value unless only a comment follows them
+	SplitAfterQuote bool
+
+	// Heredocs reads KEY=<<EOF values, the lines up to one holding only EOF are taken as they are
+	Heredocs bool
+
+	// Operators turns on the KEY?=value, KEY+=value and KEY:=value operators
+	Operators bool
+
+	// Expand turns on ${VAR} expansion when loading, see WithExpand
+	Expand bool
+
+	// ShellAssignments skips lines with a space before or after the =, a shell sourcing the file runs
+	// them as commands instead of setting the key
+	ShellAssignments bool
+
+	// TrimSpace trims all white space around keys and values, like \v and \f, not only spaces
+	TrimSpace bool
+
+	// Colons reads KEY: value lines like KEY=value, whichever of = and : comes first splits the line
+	Colons bool
+
+	// BackslashEscapes makes a backslash in a double quoted value escape any char instead of only the Escapes,
+	// only \n and \r stand for line breaks so \t is t. \$ is a literal dollar sign in any value and the value
+	// is then not expanded
+	BackslashEscapes bool
+}
+
+var (
+	// DialectDefault is what this package parses by default: # comments, continuations and the assignment operators
+	DialectDefault = Dialect{
+		CommentChars:   "#",
+		InlineComments: true,
+		Continuation:   true,
+		Quotes:         true,
+		Escapes:        `nrt"\`,
+		Heredocs:       true,
+		Operators:      true,
+	}
+
+	// DialectDocker matches `docker run --env-file`, every line is taken as it is, quotes and all
+	DialectDocker = Dialect{
+		CommentChars: "#",
+	}
+
+	// DialectSystemd matches systemd's EnvironmentFile, which also allows ; comments but has no inline ones,
+	// and only escapes what a shell does in double quotes
+	DialectSystemd = Dialect{
+		CommentChars: "#;",
+		Continuation: true,
+		Quotes:       true,
+		Escapes:      "\"\\`",
+		TrimSpace:    true,
+	}
+
+	// DialectRubyDotenv matches the ruby dotenv gem, which strips inline comments (even without a space
+	// before the #), reads KEY: value lines and expands variables
+	DialectRubyDotenv = Dialect{
+		CommentChars:     "#",
+		InlineComments:   true,
+		BareComments:     true,
+		Quotes:           true,
+		Expand:           true,
+		TrimSpace:        true,
+		Colons:           true,
+		BackslashEscapes: true,
+	}
+
+	// DialectGodotenv matches github.com/joho/godotenv: inline comments, expanding and yaml like KEY: value
+	// lines, but no operators or continuations
+	DialectGodotenv = Dialect{
+		CommentChars:     "#",
+		InlineComments:   true,
+		LastComment:      true,
+		Quotes:           true,
+		SplitAfterQuote:  true,
+		Expand:           true,
+		TrimSpace:        true,
+		Colons:           true,
+		BackslashEscapes: true,
+	}
+
+	// DialectNodeDotenv matches the node dotenv package, which strips inline comments like ruby dotenv but
+	// only unescapes \n and \r, and does not expand variables (that is dotenv-expand's job)
+	DialectNodeDotenv = Dialect{
+		CommentChars:   "#",
+		InlineComments: true,
+		BareComments:   true,
+		Quotes:         true,
+		Escapes:        "nr",
+		TrimSpace:      true,
+		Colons:         true,
+	}
+
+	// DialectPOSIX only allows what a POSIX shell would do with the file when sourcing it
+	DialectPOSIX = Dialect{
+		CommentChars:     "#",
+		InlineComments:   true,
+		Continuation:     true,
+		Quotes:           true,
+		Escapes:          "\"\\`",
+		Expand:           true,
+		ShellAssignments: true,
+	}
+)
+
+// Parse parses the content with the rules of the dialect
+func (d Dialect) Parse(content string) *Map {
+	emap := NewMap()
+
+	p := defaultParser()
+	p.Dialect = d
+	p.parse(emap, strings.NewReader(content), "")
+
+	return emap
+}
+
+// split splits a line into its key, operator and value, see parseLine
+func (d Dialect) split(line string) (key, op, val string, ok bool) {
+	if i := strings.IndexAny(line, "=:"); d.Colons && i != -1 && line[i] == ':' {
+		line = line[:i] + "=" + line[i+1:]
+	}
+
+	if i := strings.IndexByte(line, '='); d.ShellAssignments && i > 0 && (isSpace(rune(line[i-1])) || i+1 < len(line) && isSpace(rune(line[i+1]))) {
+		return "", "", "", false
+	}
+
+	key, op, val, ok = parseLine(line, d.Operators)
+	if d.TrimSpace {
+		key = strings.TrimFunc(key, isSpace)
+	}
+
+	return key, op, val, ok
+}
+
+// trim trims the spaces around s, or all white space with TrimSpace
+func (d Dialect) trim(s string) string {
+	if d.TrimSpace {
+		return strings.TrimFunc(s, isSpace)
+	}
+
+	return strings.Trim(s, " ")
+}
+
+// trimLeft trims the spaces at the start of s like trim
+func (d Dialect) trimLeft(s string) string {
+	if d.TrimSpace {
+		return strings.TrimLeftFunc(s, isSpace)
+	}
+
+	return strings.TrimLeft(s, " ")
+}
+
+// isSpace reports if r is white space within a line, the same chars godotenv trims
+func isSpace(r rune) bool {
+	switch r {
+	case ' ', '\t', '\v', '\f', '\r', 0x85, 0xa0:
+		return true
+	}
+
+	return false
+}
+
+// isComment reports if the line starts with one of the comment chars
+func (d Dialect) isComment(line string) bool {
+	return line != "" && strings.IndexByte(d.CommentChars, line[0]) != -1
+}
+
+// stripInlineComment cuts a comment off the end of a value
+func (d Dialect) stripInlineComment(val string) string {
+	if !d.InlineComments {
+		return val
+	}
+
+	start := -1
+	for i := 0; i < len(val); i++ {
+		if strings.IndexByte(d.CommentChars, val[i]) != -1 && (d.BareComments || i > 0 && d.spaceBefore(val[:i])) {
+			start = i
+			if !d.LastComment {
+				break
+			}
+		}
+	}
+
+	if start == -1 {
+		return val
+	}
+
+	return strings.TrimRight(val[:start], " \t")
+}
+
+// spaceBefore reports if s ends with the space or tab an inline comment needs before it, or any white space with TrimSpace
+func (d Dialect) spaceBefore(s string) bool {
+	if d.TrimSpace {
+		r, _ := utf8.DecodeLastRuneInString(s)
+		return isSpace(r)
+	}
+
+	return s[len(s)-1] == ' ' || s[len(s)-1] == '\t'
+}
@@ -0,0 +1,82 @@
+package env
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/joho/godotenv"
+)
+
+// dialects are the presets by the name their results have in testdata/dialects
+var dialects = map[string]Dialect{
+	"default":  DialectDefault,
+	"docker":   DialectDocker,
+	"systemd":  DialectSystemd,
+	"ruby":     DialectRubyDotenv,
+	"godotenv": DialectGodotenv,
+	"node":     DialectNodeDotenv,
+	"posix":    DialectPOSIX,
+}
+
+/*
+TestDialects parses every file of testdata/dialects with every preset. Next to each name.env is a
+name.json with what the tool each preset is named after reads from it, null if the tool rejects the
+file. The godotenv results are checked against godotenv itself too
+*/
+func TestDialects(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "dialects", "*.env"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no cases in testdata/dialects: %v", err)
+	}
+
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".env")
+
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := ioutil.ReadFile(strings.TrimSuffix(file, ".env") + ".json")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var want map[string]*map[string]string
+		if err := json.Unmarshal(data, &want); err != nil {
+			t.Fatalf("%s.json: %s", name, err)
+		}
+
+		for preset, d := range dialects {
+			t.Run(name+"/"+preset, func(t *testing.T) {
+				w, ok := want[preset]
+				if !ok {
+					t.Fatalf("%s.json has no result for %s", name, preset)
+				}
+				if w == nil {
+					t.Skipf("%s rejects the file", preset)
+				}
+
+				got := d.Parse(string(content)).Map
+				if !reflect.DeepEqual(map[string]string(got), *w) {
+					t.Errorf("%s parses %q to %q, want %q", preset, content, got, *w)
+				}
+			})
+		}
+
+		t.Run(name+"/godotenv itself", func(t *testing.T) {
+			got, err := godotenv.Unmarshal(string(content))
+			if w := want["godotenv"]; w == nil {
+				if err == nil {
+					t.Errorf("godotenv parses %q to %q, want an error", content, got)
+				}
+			} else if !reflect.DeepEqual(got, *w) {
+				t.Errorf("godotenv parses %q to %q (%v), want %q", content, got, err, *w)
+			}
+		})
+	}
+}
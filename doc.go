@@ -0,0 +1,10 @@
+/*
+Package env loads env vars from .env files and from adapters that pull secrets from other places,
+like AWS secrets manager, and sets them to the env of your application.
+
+The package level functions (Load, MustLoad, RequiredKeys, ApplyAdapter...) share one default Loader
+owned by the application. Importing the package does no I/O and changes nothing, so libraries
+that want to load their own env should create a Loader with NewLoader and never touch the package
+level functions
+*/
+package env
@@ -0,0 +1,172 @@
+package env
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+/*
+Document is an env file kept line by line, so it can be changed and written back with its comments,
+blank lines and the order of its keys as they were. Unlike a Map, which only holds what the file sets
+
+	doc, err := env.ReadDocument(".env")
+	if err != nil {
+		return err
+	}
+
+	err = doc.Set("API_TOKEN", token)
+	if err != nil {
+		return err
+	}
+	doc.Delete("OLD_TOKEN")
+
+	err = doc.WriteFile(".env")
+*/
+type Document struct {
+	file fileLines
+}
+
+// ParseDocument parses the content of an env file into a Document
+func ParseDocument(content []byte) *Document {
+	return &Document{file: splitFile(content)}
+}
+
+// ReadDocument reads the env file into a Document
+func ReadDocument(path string) (*Document, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseDocument(data), nil
+}
+
+// Keys returns the keys the document sets in the order they are first set
+func (d *Document) Keys() []string {
+	var keys []string
+
+	for _, e := range fileEntries(d.file.lines) {
+		if !hasString(keys, e.key) {
+			keys = append(keys, e.key)
+		}
+	}
+
+	return keys
+}
+
+// Lookup returns the value the document sets the key to and if it sets it
+func (d *Document) Lookup(key string) (string, bool) {
+	val, ok := d.Map().Map[key]
+
+	return val, ok
+}
+
+// Map parses the document into a Map, the same as Parse
+func (d *Document) Map() *Map {
+	return Parse(d.String())
+}
+
+/*
+Set sets the key to value. The line setting the key is replaced (the last one, if it is set more than
+once) and keeps its place and the comments around it, a key that is not in the document is added to
+the end
+*/
+func (d *Document) Set(key, value string) error {
+	line, err := formatLine(key, value)
+	if err != nil {
+		return err
+	}
+
+	d.file.lines = upsert(d.file.lines, key, line)
+	return nil
+}
+
+// Delete removes every line setting the key and reports if there were any, the comments around them are kept
+func (d *Document) Delete(key string) bool {
+	entries := fileEntries(d.file.lines)
+
+	deleted := false
+	for i := len(entries) - 1; i >= 0; i-- {
+		if e := entries[i]; e.key == key {
+			d.file.lines = append(d.file.lines[:e.start], d.file.lines[e.end+1:]...)
+			deleted = true
+		}
+	}
+
+	return deleted
+}
+
+// Rename renames every line setting from to set to instead, keeping the values and comments as they are,
+// and reports if there were any
+func (d *Document) Rename(from, to string) (bool, error) {
+	if !isName(to) {
+		return false, fmt.Errorf("invalid key %q", to)
+	}
+
+	renamed := false
+	for _, e := range fileEntries(d.file.lines) {
+		if e.key != from {
+			continue
+		}
+
+		// keep the indentation and export in front of the key
+		line := d.file.lines[e.start]
+		prefix := len(line) - len(strings.TrimLeft(trimExport(strings.TrimLeft(line, " ")), " \t"))
+		if strings.HasPrefix(line[prefix:], from) {
+			d.file.lines[e.start] = line[:prefix] + to + line[prefix+len(from):]
+			renamed = true
+		}
+	}
+
+	return renamed, nil
+}
+
+// String returns the document as the content of an env file
+func (d *Document) String() string {
+	return string(d.Bytes())
+}
+
+// Bytes returns the document as the content of an env file, with the line endings it was parsed with
+func (d *Document) Bytes() []byte {
+	if len(d.file.lines) == 0 {
+		return []byte(d.file.prefix)
+	}
+
+	return d.file.join()
+}
+
+// WriteFile writes the document to path atomically and under the same locks as UpsertInFile, keeping the
+// permissions of the file if it exists. The file is replaced with the document, use EditDocument to
+// change it without losing what others write between reading and writing it
+func (d *Document) WriteFile(path string) error {
+	return editLines(path, func(f *fileLines) error {
+		*f = d.file
+		f.lines = append([]string(nil), d.file.lines...)
+		return nil
+	})
+}
+
+/*
+EditDocument reads the env file into a Document, lets edit change it and writes it back, holding the
+same locks as UpsertInFile the whole time so no other write can come in between and be lost. A file
+that does not exist starts out empty, and nothing is written when edit returns an error
+
+	err := env.EditDocument(".env", func(doc *env.Document) error {
+		doc.Delete("OLD_TOKEN")
+		return doc.Set("API_TOKEN", token)
+	})
+*/
+func EditDocument(path string, edit func(doc *Document) error) error {
+	return editLines(path, func(f *fileLines) error {
+		doc := &Document{file: *f}
+
+		err := edit(doc)
+		if err != nil {
+			return err
+		}
+
+		*f = doc.file
+		return nil
+	})
+}
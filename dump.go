@@ -0,0 +1,66 @@
+package env
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+/*
+Dump writes the keys the Loader has set to the env as KEY=value lines, with where each one came from.
+Secret values (see Map.IsSecret) are masked, so the output is safe to put in logs. A key that was
+changed or unset since it was loaded shows its current value and says so
+*/
+func (l *Loader) Dump(w io.Writer) error {
+	l.mu.Lock()
+	loaded := NewMap()
+	if l.loaded != nil {
+		loaded.SetMap(l.loaded)
+	}
+	l.mu.Unlock()
+
+	var b strings.Builder
+	for _, key := range loaded.Keys() {
+		source := loaded.Source(key)
+		if source == "" {
+			source = "unknown"
+		}
+
+		val, ok := os.LookupEnv(key)
+		if !ok {
+			fmt.Fprintf(&b, "# %s was loaded from %s but is unset now\n", key, source)
+			continue
+		}
+
+		if val != loaded.Map[key] {
+			source += ", changed since it was loaded"
+		}
+		if val != "" && loaded.IsSecret(key) {
+			val = RedactedValue
+		}
+
+		fmt.Fprintf(&b, "%s=%s # %s\n", key, val, source)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// logWriter writes to the logger of the Loader, one call per line
+type logWriter struct {
+	logf func(format string, args ...interface{})
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimSuffix(string(p), "\n"), "\n") {
+		w.logf("%s", line)
+	}
+
+	return len(p), nil
+}
+
+// DumpOnSignal calls Dump on the default Loader every time the process gets SIGUSR1, see Loader.DumpOnSignal
+func DumpOnSignal(w io.Writer) (stop func()) {
+	return getDefaultLoader().DumpOnSignal(w)
+}
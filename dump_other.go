@@ -0,0 +1,21 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package env
+
+import (
+	"context"
+	"io"
+)
+
+// DumpOnSignal does nothing since there is no SIGUSR1 on this platform, call Dump directly instead
+func (l *Loader) DumpOnSignal(w io.Writer) (stop func()) {
+	return func() {}
+}
+
+// RunDumpOnSignal waits for ctx to be done and returns nil, there is no SIGUSR1 on this platform
+func (l *Loader) RunDumpOnSignal(ctx context.Context, w io.Writer) error {
+	<-ctx.Done()
+
+	return nil
+}
@@ -0,0 +1,73 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package env
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+/*
+DumpOnSignal writes the env the Loader has loaded to w every time the process gets SIGUSR1, so
+operators can see what a running process is configured with. When w is nil it goes to the logger of
+the Loader (see WithLogger). stop stops listening for the signal, RunDumpOnSignal does the same until
+a context is done
+
+	kill -USR1 <pid>
+
+Nothing happens on platforms without SIGUSR1, like windows
+*/
+func (l *Loader) DumpOnSignal(w io.Writer) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	signals := l.notifyDump()
+	go func() {
+		defer close(done)
+		l.dumpOnSignal(ctx, signals, w)
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cancel()
+			<-done
+		})
+	}
+}
+
+// RunDumpOnSignal is DumpOnSignal until ctx is done, then it stops listening for the signal and returns nil
+func (l *Loader) RunDumpOnSignal(ctx context.Context, w io.Writer) error {
+	l.dumpOnSignal(ctx, l.notifyDump(), w)
+
+	return nil
+}
+
+func (l *Loader) notifyDump() chan os.Signal {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+
+	return signals
+}
+
+func (l *Loader) dumpOnSignal(ctx context.Context, signals chan os.Signal, w io.Writer) {
+	defer signal.Stop(signals)
+
+	if w == nil {
+		w = logWriter{logf: l.config().logf}
+	}
+
+	for {
+		select {
+		case <-signals:
+			l.Dump(w)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
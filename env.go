@@ -2,29 +2,267 @@ package env
 
 import (
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 )
 
 // Env map type
 type EnvMap map[string]string
 
-// Map is a struct that contains the map parsed lines from the env file and methods to update the map
+// Map is a struct that contains the map parsed lines from the env file and methods to update the map.
+// A Map is not safe for concurrent use, the package level functions (Load, RequiredKeys, ApplyAdapter...) and Loader are
 type Map struct {
 	Map EnvMap
+
+	// info holds what else is known about a key, like the comment above it in the file
+	info map[string]*keyInfo
+
+	// order holds the keys in the order Set first saw them, see Ordered
+	order []string
 }
 
+type keyInfo struct {
+	description string
+	tags        []string
+	source      string
+
+	// literal keys were single quoted, they are never expanded
+	literal bool
+
+	// empty keys were set to an empty value on purpose, see IsEmpty
+	empty bool
+}
+
+// RedactedValue replaces the values of redacted keys
+const RedactedValue = "********"
+
 // Sets the key and value to the map
 func (e *Map) Set(key, val string) {
+	if _, ok := e.Map[key]; !ok {
+		e.order = append(e.order, key)
+	}
 	e.Map[key] = val
 }
 
+/*
+Ordered returns the keys in the order they were first set, which for a parsed file is the order of
+its lines and for layered files is the order they were loaded in. Keys put in Map without Set come
+last, sorted. Unlike ranging over Map it is the same every run, so output and errors are too
+*/
+func (e *Map) Ordered() []string {
+	keys := make([]string, 0, len(e.Map))
+	seen := make(map[string]bool, len(e.Map))
+
+	for _, key := range e.order {
+		if _, ok := e.Map[key]; ok && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	if len(keys) < len(e.Map) {
+		var rest []string
+		for key := range e.Map {
+			if !seen[key] {
+				rest = append(rest, key)
+			}
+		}
+		sort.Strings(rest)
+		keys = append(keys, rest...)
+	}
+
+	return keys
+}
+
 // SetMap reads all keys and values from the EnvMap and sets to another EnvMap.Env
 func (e *Map) SetMap(target *Map) {
-	for key, val := range target.Map {
-		e.Set(key, val)
+	for _, key := range target.Ordered() {
+		e.Set(key, target.Map[key])
+	}
+
+	for key, info := range target.info {
+		if info.description != "" {
+			e.SetDescription(key, info.description)
+		}
+		if info.source != "" {
+			e.SetSource(key, info.source)
+		}
+		e.keyInfo(key).literal = info.literal
+		e.keyInfo(key).empty = info.empty
+		e.AddTags(key, info.tags...)
+	}
+}
+
+/*
+IsEmpty reports if the key is set to an empty value on purpose, by a `KEY=""` line in its env file or
+SetEmpty. A `KEY=` line is set too, but reads as a value that was left blank, so MustLoad still
+counts it as missing when it is required
+*/
+func (e *Map) IsEmpty(key string) bool {
+	val, ok := e.Map[key]
+	info, known := e.info[key]
+
+	return ok && val == "" && known && info.empty
+}
+
+// SetEmpty sets the key to an empty value on purpose, see IsEmpty
+func (e *Map) SetEmpty(key string) {
+	e.Set(key, "")
+	e.keyInfo(key).empty = true
+}
+
+// Description returns the comment written above the key in its env file
+func (e *Map) Description(key string) string {
+	if info, ok := e.info[key]; ok {
+		return info.description
+	}
+
+	return ""
+}
+
+// SetDescription sets the description of a key
+func (e *Map) SetDescription(key, description string) {
+	e.keyInfo(key).description = description
+}
+
+// Source returns where the key was loaded from, the name of the file or the adapter
+func (e *Map) Source(key string) string {
+	if info, ok := e.info[key]; ok {
+		return info.source
+	}
+
+	return ""
+}
+
+// SetSource sets where the key was loaded from
+func (e *Map) SetSource(key, source string) {
+	e.keyInfo(key).source = source
+}
+
+// Tags returns the tags of the key, set in its env file with a `# @tag:name` comment above it
+func (e *Map) Tags(key string) []string {
+	if info, ok := e.info[key]; ok {
+		return append([]string(nil), info.tags...)
+	}
+
+	return nil
+}
+
+// AddTags tags the key
+func (e *Map) AddTags(key string, tags ...string) {
+	info := e.keyInfo(key)
+
+	for _, tag := range tags {
+		if !hasString(info.tags, tag) {
+			info.tags = append(info.tags, tag)
+		}
+	}
+}
+
+// secretWords are the parts of a key name that mark it as a secret
+var secretWords = []string{"SECRET", "PASSWORD", "PASSWD", "TOKEN", "KEY", "CREDENTIAL", "PRIVATE", "AUTH"}
+
+// IsSecret reports if the key is tagged secret or has a name that looks like a secret, like DB_PASSWORD or API_TOKEN
+func (e *Map) IsSecret(key string) bool {
+	if info, ok := e.info[key]; ok && hasString(info.tags, "secret") {
+		return true
+	}
+
+	name := strings.ToUpper(key)
+	for _, word := range secretWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Tagged returns the sorted keys that have the tag
+func (e *Map) Tagged(tag string) []string {
+	var keys []string
+
+	for key := range e.Map {
+		if info, ok := e.info[key]; ok && hasString(info.tags, tag) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// ExportTagged returns a new map with only the keys that have the tag
+func (e *Map) ExportTagged(tag string) *Map {
+	m := NewMap()
+
+	for _, key := range e.Tagged(tag) {
+		m.Set(key, e.Map[key])
+		m.copyInfo(e, key)
 	}
+
+	return m
+}
+
+// RedactTagged returns a copy of the map where the values of keys with the tag are replaced with RedactedValue
+func (e *Map) RedactTagged(tag string) *Map {
+	m := NewMap()
+	m.SetMap(e)
+
+	for _, key := range e.Tagged(tag) {
+		m.Set(key, RedactedValue)
+	}
+
+	return m
+}
+
+// filter returns a new map with only the keys keep returns true for
+func (e *Map) filter(keep func(key string) bool) *Map {
+	m := NewMap()
+
+	for _, key := range e.Ordered() {
+		if keep(key) {
+			m.Set(key, e.Map[key])
+			m.copyInfo(e, key)
+		}
+	}
+
+	return m
+}
+
+func (e *Map) copyInfo(from *Map, key string) {
+	if info, ok := from.info[key]; ok {
+		e.SetDescription(key, info.description)
+		e.SetSource(key, info.source)
+		e.keyInfo(key).literal = info.literal
+		e.keyInfo(key).empty = info.empty
+		e.AddTags(key, info.tags...)
+	}
+}
+
+// isLiteral reports if the value of the key was single quoted
+func (e *Map) isLiteral(key string) bool {
+	info, ok := e.info[key]
+
+	return ok && info.literal
+}
+
+// keyInfo returns the info of the key, creating it if needed
+func (e *Map) keyInfo(key string) *keyInfo {
+	if e.info == nil {
+		e.info = make(map[string]*keyInfo)
+	}
+
+	info, ok := e.info[key]
+	if !ok {
+		info = &keyInfo{}
+		e.info[key] = info
+	}
+
+	return info
 }
 
 // NewEnvMap creates and returns EnvMap, as well as cretaing the map
@@ -37,16 +275,60 @@ func NewMap() *Map {
 
 // Adapter is a interface for pulling  secrets from a external secerts storage service (ex. AWS secret manager) and exporting them in your application
 type Adapter struct {
+	// Name is used in errors and as the source of the keys it returns, by default "adapter <n>"
+	Name string
+
 	// Pull fucntion will be where secrets will be retrieved and will return a EnvMap
 	Pull func() (*Map, error)
+
+	// PullFrom is used instead of Pull when it is set, for adapters that read their settings (like
+	// credentials) from what was loaded before them: loaded looks keys up in the adapters that ran
+	// before, then the files and then the env
+	PullFrom func(loaded Reader) (*Map, error)
+
+	// Push is optional, it sets the keys of the map where Pull gets them from, see Loader.Push
+	Push func(emap *Map) error
+
+	// Network is set for adapters that reach over the network, WithOffline skips them unless
+	// they are Required, then the load fails
+	Network  bool
+	Required bool
+}
+
+// loadedAdapter creates an adapter that reads its settings from what was loaded before it, its Pull reads them from the env
+func loadedAdapter(name string, network bool, pull func(loaded Reader) (*Map, error)) *Adapter {
+	return &Adapter{
+		Name:     name,
+		Network:  network,
+		PullFrom: pull,
+		Pull: func() (*Map, error) {
+			return pull(Environ())
+		},
+	}
 }
 
 var (
 	envFileNames = []string{".env"}
-	requiredKeys []string
-	adapters     []*Adapter
+
+	// mu guards defaultLoader so ResetGlobals can swap it while other goroutines are loading
+	mu            sync.Mutex
+	defaultLoader = newDefaultLoader()
 )
 
+// newDefaultLoader creates the loader behind the package level functions, unlike NewLoader it reports skipped files
+func newDefaultLoader() *Loader {
+	return NewLoader(WithLogger(func(format string, args ...interface{}) {
+		fmt.Printf(format+"\n", args...)
+	}))
+}
+
+func getDefaultLoader() *Loader {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return defaultLoader
+}
+
 /* Load scans one or mores that are given and exports the vairbles in the file if they do not exist.
 if a file is not provided then the `.env` file in the current working directory will be scaned
 instead if one was found.
@@ -55,48 +337,17 @@ After that happens load will run the adapters if any were provided then it will
 to return a env map that will be exported as well
 */
 func Load(filenames ...string) error {
-	if len(filenames) == 0 {
-		filenames = envFileNames
-	}
-
-	// load files
-	files, err := loadFiles(false, filenames...)
-	if err != nil {
-		return err
-	}
-
-	globalEnvMap := NewMap()
-
-	// parse files
-	for _, content := range files {
-		// parse file
-		emap := Parse(content)
-
-		globalEnvMap.SetMap(emap)
-	}
-
-	if len(adapters) != 0 {
-		// run pull secrets from adapters
-		for _, adapter := range adapters {
-
-			// pulling secrets
-			emap, err := adapter.Pull()
-			if err != nil {
-				return fmt.Errorf("error occured running adapter: %s", err)
-			}
-
-			// set adapters EnvMap to global EnvMap
-			globalEnvMap.SetMap(emap)
-		}
-	}
+	return getDefaultLoader().Load(filenames...)
+}
 
-	// set env map to env
-	err = setEnvMap(globalEnvMap)
-	if err != nil {
-		return err
-	}
+// LoadReader loads env content from r instead of a file, see Loader.LoadReader
+func LoadReader(r io.Reader) error {
+	return getDefaultLoader().LoadReader(r)
+}
 
-	return nil
+// Overload is Load but overrides the env vars that are already set, so the files and adapters always win
+func Overload(filenames ...string) error {
+	return getDefaultLoader().Overload(filenames...)
 }
 
 /* Load scans one or mores that are given and exports the vairbles in the file if they do not exist.
@@ -109,158 +360,169 @@ to return a env map that will be exported as well.
 This will error if a required key/s are missing if require keys were provided
 */
 func MustLoad(filenames ...string) error {
-	err := Load(filenames...)
-	if err != nil {
-		return err
-	}
-
-	// check for missing required keys
-	if len(requiredKeys) != 0 {
-		var missingKeys []string
+	return getDefaultLoader().MustLoad(filenames...)
+}
 
-		for _, key := range requiredKeys {
-			val, ok := os.LookupEnv(key)
+/*
+LoadOnce calls Load only the first time it is called, so a library and the main package can both
+call it without adapters being ran twice. Later calls return the error from the first call.
 
-			if !ok && val == "" {
-				missingKeys = append(missingKeys, key)
-			}
-
-		}
+If a later call asks for different files than the first one it returns ErrLoadedWithDifferentFiles
+so the conflict does not go unnoticed
+*/
+func LoadOnce(filenames ...string) error {
+	return getDefaultLoader().LoadOnce(filenames...)
+}
 
-		if len(missingKeys) != 0 {
-			return fmt.Errorf("Required keys missing or empty: %s", missingKeys)
-		}
-	}
+// LoadOnly loads the `.env` file and the adapters, but only sets the given keys to the env
+func LoadOnly(keys ...string) error {
+	return getDefaultLoader().LoadOnly(keys...)
+}
 
-	return nil
+// LoadMatching loads the `.env` file and the adapters, but only sets the keys matching the glob pattern (e.g. `DB_*`)
+func LoadMatching(pattern string) error {
+	return getDefaultLoader().LoadMatching(pattern)
 }
 
 // LoadSecrets will run all your adapters and set all the env vars that were fetch then set them to your env in your application
 func LoadSecrets() error {
-	globalEnvMap := NewMap()
-
-	if len(adapters) != 0 {
-		// run pull secrets from adapters
-		for _, adapter := range adapters {
-
-			// pulling secrets
-			emap, err := adapter.Pull()
-			if err != nil {
-				return fmt.Errorf("error occured running adapter: %s", err)
-			}
-
-			// set adapters EnvMap to global EnvMap
-			globalEnvMap.SetMap(emap)
-		}
-	}
-
-	// set env map to env
-	err := setEnvMap(globalEnvMap)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return getDefaultLoader().LoadSecrets()
 }
 
 /* Must LoadSecrets will run all your adapters and set all the env vars that were fetch then set them to your env in your application.
 As well as checking for required secrets */
 func MustLoadSecrets() error {
-	err := LoadSecrets()
-	if err != nil {
-		return err
-	}
+	return getDefaultLoader().MustLoadSecrets()
+}
 
-	// check for missing required keys
-	if len(requiredKeys) != 0 {
-		var missingKeys []string
+// LoadedKeys returns the sorted keys that Load and the other package level functions have set to the env
+func LoadedKeys() []string {
+	return getDefaultLoader().LoadedKeys()
+}
 
-		for _, key := range requiredKeys {
-			val, ok := os.LookupEnv(key)
+// LoadedFiles returns the files the last load read, see Loader.LoadedFiles
+func LoadedFiles() []string {
+	return getDefaultLoader().LoadedFiles()
+}
 
-			if !ok && val == "" {
-				missingKeys = append(missingKeys, key)
-			}
+// Configure changes the options used by Load and the other package level functions
+func Configure(opts ...Option) {
+	getDefaultLoader().Configure(opts...)
+}
 
-		}
+// RequiredKeys is a way for you to set a checkpoint when loading secrets or required exported variables for your application
+func RequiredKeys(keys []string) {
+	getDefaultLoader().RequiredKeys(keys)
+}
 
-		if len(missingKeys) != 0 {
-			return fmt.Errorf("Required keys missing or empty: %s", missingKeys)
-		}
-	}
+// RequiredTagged makes MustLoad and MustLoadSecrets require every key that has one of the tags
+func RequiredTagged(tags ...string) {
+	getDefaultLoader().RequiredTagged(tags...)
+}
 
-	return nil
+// OnKey adds a hook that is called for every key that is loaded, see KeyHook
+func OnKey(hook KeyHook) {
+	getDefaultLoader().OnKey(hook)
 }
 
-// RequiredKeys is a way for you to set a checkpoint when loading secrets or required exported variables for your application
-func RequiredKeys(keys []string) {
-	requiredKeys = append(requiredKeys, keys...)
+// Derive adds a key that is computed from the others once everything is loaded, see Loader.Derive
+func Derive(key string, fn func(m *Map) string) {
+	getDefaultLoader().Derive(key, fn)
+}
+
+// Push sets the keys of emap with the named adapter after confirm approves the diff, see Loader.Push
+func Push(adapter string, emap *Map, confirm func(changes []Change) bool) error {
+	return getDefaultLoader().Push(adapter, emap, confirm)
 }
 
 // ApplyAdapter will set middleware, when Load or MustLoad is called those middleware will be called
 func ApplyAdapter(a ...*Adapter) {
-	adapters = append(adapters, a...)
+	getDefaultLoader().ApplyAdapter(a...)
 }
 
-// helper functions
-
-func loadFiles(strict bool, filenames ...string) ([]string, error) {
-	var files []string
+// ResetGlobals clears the required keys and adapters that were set and lets LoadOnce load again, mostly useful between tests
+func ResetGlobals() {
+	mu.Lock()
+	defer mu.Unlock()
 
-	for _, filename := range filenames {
-		f, err := os.Stat(filename)
-		if err != nil {
-			fmt.Printf("could not load %s: %s\n", filename, err)
-			continue
-		}
+	defaultLoader = newDefaultLoader()
+}
 
-		if f.IsDir() {
-			fmt.Printf("Could not load %s: %s", f.Name(), err)
-			continue
-		}
+// helper functions
 
-		bytes, err := ioutil.ReadFile(f.Name())
-		if err != nil {
-			return files, nil
+func hasString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
 		}
-
-		files = append(files, string(bytes))
 	}
 
-	return files, nil
+	return false
 }
 
-func setEnvMap(target *Map) error {
-	for key, val := range target.Map {
-		err := os.Setenv(key, val)
+/*
+setEnvMap sets the map to the env all or nothing. Every key and value is checked, along with the size
+of the env, before anything is set and if setting one still fails the keys already set are put back the way they were
+*/
+func setEnvMap(cfg settings, target *Map) error {
+	keys := target.Ordered()
+
+	for _, key := range keys {
+		err := checkEnvVar(key, target.Map[key])
 		if err != nil {
 			return err
 		}
 	}
 
-	return nil
-}
+	err := checkEnvSize(cfg.logf, target)
+	if err != nil {
+		return err
+	}
 
-// Parse takes a io.Reader that will parsed and returns a env map
-func Parse(content string) *Map {
-	emap := NewMap()
+	type previous struct {
+		key string
+		val string
+		ok  bool
+	}
+	var applied []previous
 
-	lines := strings.Split(content, "\n")
-	for _, line := range lines {
-		line = strings.Trim(line, " ")
+	for _, key := range keys {
+		val := target.Map[key]
+		prev, ok := os.LookupEnv(key)
 
-		if !strings.HasPrefix(line, "#") && line != "" {
-			key, val := parseLine(line)
+		err := os.Setenv(key, val)
+		if err != nil {
+			// roll back in reverse so a key is restored to what it was before Load
+			for i := len(applied) - 1; i >= 0; i-- {
+				if applied[i].ok {
+					os.Setenv(applied[i].key, applied[i].val)
+				} else {
+					os.Unsetenv(applied[i].key)
+				}
+			}
 
-			emap.Set(key, val)
+			return fmt.Errorf("could not set %s, no env vars were changed: %s", key, err)
 		}
+
+		applied = append(applied, previous{key: key, val: prev, ok: ok})
 	}
 
-	return emap
+	return nil
 }
 
-func parseLine(line string) (string, string) {
-	splitLine := strings.SplitN(line, "=", 2)
+// checkEnvVar errors on keys and values the OS would refuse to set
+func checkEnvVar(key, val string) error {
+	if key == "" {
+		return fmt.Errorf("can not set an env var with an empty key")
+	}
+
+	if strings.ContainsAny(key, "=\x00") {
+		return fmt.Errorf("can not set env var %q: key has a = or NUL character", key)
+	}
 
-	return splitLine[0], splitLine[1]
+	if strings.ContainsRune(val, 0) {
+		return fmt.Errorf("can not set env var %s: value has a NUL character", key)
+	}
+
+	return nil
 }
@@ -0,0 +1,26 @@
+/*
+Package envexpvar publishes what an env.Loader loaded as an expvar variable, so it shows up in /debug/vars
+
+	envexpvar.Publish("env")
+
+It is a package of its own since importing expvar registers /debug/vars on http.DefaultServeMux, and
+importing env should not do anything
+*/
+package envexpvar
+
+import (
+	"expvar"
+
+	"github.com/andreGarvin/env"
+)
+
+// Publish publishes what the default Loader loaded as the expvar variable name, the same as env.Handler
+// serves. Like expvar.Publish it panics if the name is already published
+func Publish(name string) {
+	expvar.Publish(name, expvar.Func(env.Debug))
+}
+
+// PublishLoader is Publish for the Loader
+func PublishLoader(name string, l *env.Loader) {
+	expvar.Publish(name, expvar.Func(l.Debug))
+}
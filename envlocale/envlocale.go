@@ -0,0 +1,47 @@
+/*
+Package envlocale reads env vars as the types of golang.org/x/text, a language.Tag and a currency.Unit
+
+	tag, err := envlocale.GetLanguage("DEFAULT_LOCALE")
+
+The getters of env return strings since env only uses the standard library, these parse the same
+values with x/text, which also checks the subtags and codes are registered
+*/
+package envlocale
+
+import (
+	"fmt"
+
+	"github.com/andreGarvin/env"
+	"golang.org/x/text/currency"
+	"golang.org/x/text/language"
+)
+
+// GetLanguage returns the env var as a language.Tag, POSIX locales like en_US.UTF-8 are taken too, see env.GetLanguage
+func GetLanguage(key string) (language.Tag, error) {
+	val, err := env.GetLanguage(key)
+	if err != nil {
+		return language.Und, err
+	}
+
+	tag, err := language.Parse(val)
+	if err != nil {
+		return language.Und, fmt.Errorf("%s is not a language tag: %s", key, err)
+	}
+
+	return tag, nil
+}
+
+// GetCurrency returns the env var as a currency.Unit, see env.GetCurrency
+func GetCurrency(key string) (currency.Unit, error) {
+	val, err := env.GetCurrency(key)
+	if err != nil {
+		return currency.Unit{}, err
+	}
+
+	unit, err := currency.ParseISO(val)
+	if err != nil {
+		return currency.Unit{}, fmt.Errorf("%s is not an ISO 4217 currency code: %s", key, err)
+	}
+
+	return unit, nil
+}
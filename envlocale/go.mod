@@ -0,0 +1,8 @@
+module github.com/andreGarvin/env/envlocale
+
+go 1.17
+
+require (
+	github.com/andreGarvin/env v0.0.0-00010101000000-000000000000
+	golang.org/x/text v0.3.8
+)
@@ -0,0 +1,53 @@
+/*
+Package envlogrus sets the level of a logrus logger from an env var, with the level names
+env.GetLogLevel takes
+
+	err := envlogrus.SetLevel(logrus.StandardLogger(), "LOG_LEVEL")
+
+It is a package of its own so importing env does not pull in logrus
+*/
+package envlogrus
+
+import (
+	"github.com/andreGarvin/env"
+	"github.com/sirupsen/logrus"
+)
+
+// Level returns the logrus.Level of the level
+func Level(l env.LogLevel) logrus.Level {
+	switch l {
+	case env.LevelTrace:
+		return logrus.TraceLevel
+	case env.LevelDebug:
+		return logrus.DebugLevel
+	case env.LevelWarn:
+		return logrus.WarnLevel
+	case env.LevelError:
+		return logrus.ErrorLevel
+	case env.LevelFatal:
+		return logrus.FatalLevel
+	}
+
+	return logrus.InfoLevel
+}
+
+// GetLevel returns the env var as a logrus.Level, see env.GetLogLevel
+func GetLevel(key string) (logrus.Level, error) {
+	l, err := env.GetLogLevel(key)
+	if err != nil {
+		return logrus.InfoLevel, err
+	}
+
+	return Level(l), nil
+}
+
+// SetLevel sets the level of the logger to the env var, it is left as it is when the env var is not set or not a level
+func SetLevel(logger *logrus.Logger, key string) error {
+	l, err := GetLevel(key)
+	if err != nil {
+		return err
+	}
+	logger.SetLevel(l)
+
+	return nil
+}
@@ -0,0 +1,10 @@
+module github.com/andreGarvin/env/envlogrus
+
+go 1.17
+
+require (
+	github.com/andreGarvin/env v0.0.0-00010101000000-000000000000
+	github.com/sirupsen/logrus v1.9.3
+)
+
+require golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
@@ -0,0 +1,173 @@
+// Package envtest has helpers for testing code built on top of env, like
+// asserting the output of an exporter against a golden file.
+package envtest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/andreGarvin/env"
+)
+
+// Mask is the text secret values are replaced with before comparing against a golden file
+const Mask = env.RedactedValue
+
+// UpdateEnv is the env var that makes AssertGolden write the golden files instead of comparing, like
+// ENVTEST_UPDATE=1 go test ./...
+const UpdateEnv = "ENVTEST_UPDATE"
+
+// Option changes how output is normalized before it is compared with a golden file
+type Option func(*golden)
+
+type golden struct {
+	secrets []string
+	sort    bool
+	update  bool
+}
+
+// MaskValues replaces every occurrence of the given values in the output with Mask,
+// so real secrets never end up committed in a golden file
+func MaskValues(values ...string) Option {
+	return func(g *golden) {
+		g.secrets = append(g.secrets, values...)
+	}
+}
+
+// MaskKeys masks the values the given keys have in the map
+func MaskKeys(m *env.Map, keys ...string) Option {
+	return func(g *golden) {
+		for _, key := range keys {
+			if val, ok := m.Map[key]; ok {
+				g.secrets = append(g.secrets, val)
+			}
+		}
+	}
+}
+
+// SortLines sorts the output lines, for exporters that do not write keys in a stable order
+func SortLines() Option {
+	return func(g *golden) {
+		g.sort = true
+	}
+}
+
+// Update makes AssertGolden write the golden file when on, to hook it up to a flag of your own
+//
+//	var update = flag.Bool("update", false, "update the golden files")
+//	envtest.AssertGolden(t, path, got, envtest.Update(*update))
+func Update(on bool) Option {
+	return func(g *golden) {
+		g.update = g.update || on
+	}
+}
+
+// Render writes the map as sorted KEY=VALUE lines, handy for testing a Map directly
+func Render(m *env.Map) []byte {
+	var keys []string
+	for key := range m.Map {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, key := range keys {
+		buf.WriteString(key + "=" + m.Map[key] + "\n")
+	}
+
+	return buf.Bytes()
+}
+
+// Normalize applies the options to the output the same way AssertGolden does
+func Normalize(got []byte, opts ...Option) []byte {
+	g := &golden{}
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	out := strings.ReplaceAll(string(got), "\r\n", "\n")
+
+	// mask the longest values first so a secret containing another one is fully masked
+	secrets := append([]string(nil), g.secrets...)
+	sort.Slice(secrets, func(i, j int) bool {
+		return len(secrets[i]) > len(secrets[j])
+	})
+	for _, secret := range secrets {
+		if secret != "" {
+			out = strings.ReplaceAll(out, secret, Mask)
+		}
+	}
+
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	if g.sort {
+		sort.Strings(lines)
+	}
+
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+/*
+AssertGolden compares the output against the golden file at path after normalizing it with the options.
+
+Running the tests with ENVTEST_UPDATE=1, or passing Update(true), writes the normalized output to the
+golden file instead
+*/
+func AssertGolden(t testing.TB, path string, got []byte, opts ...Option) {
+	t.Helper()
+
+	g := &golden{}
+	for _, opt := range opts {
+		opt(g)
+	}
+	if on, err := strconv.ParseBool(os.Getenv(UpdateEnv)); err == nil && on {
+		g.update = true
+	}
+
+	got = Normalize(got, opts...)
+
+	if g.update {
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err == nil {
+			err = ioutil.WriteFile(path, got, 0644)
+		}
+		if err != nil {
+			t.Fatalf("could not update golden file %s: %s", path, err)
+		}
+		return
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("could not read golden file %s (run with ENVTEST_UPDATE=1 to create it): %s", path, err)
+	}
+	want = bytes.ReplaceAll(want, []byte("\r\n"), []byte("\n"))
+
+	if bytes.Equal(got, want) {
+		return
+	}
+
+	gotLines := strings.Split(string(got), "\n")
+	wantLines := strings.Split(string(want), "\n")
+	for i := 0; i < len(gotLines) || i < len(wantLines); i++ {
+		var g, w string
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+
+		if g != w {
+			t.Errorf("output does not match golden file %s at line %d:\n got: %q\nwant: %q", path, i+1, g, w)
+			return
+		}
+	}
+
+	// the lines only differ in line breaks at the end
+	t.Errorf("output does not match golden file %s:\n got: %q\nwant: %q", path, got, want)
+}
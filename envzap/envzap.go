@@ -0,0 +1,52 @@
+/*
+Package envzap sets the level of a zap logger from an env var, with the level names env.GetLogLevel takes
+
+	level := zap.NewAtomicLevel()
+	err := envzap.SetLevel(level, "LOG_LEVEL")
+
+It is a package of its own so importing env does not pull in zap
+*/
+package envzap
+
+import (
+	"github.com/andreGarvin/env"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Level returns the zapcore.Level of the level, zap has no trace so it is debug
+func Level(l env.LogLevel) zapcore.Level {
+	switch l {
+	case env.LevelTrace, env.LevelDebug:
+		return zapcore.DebugLevel
+	case env.LevelWarn:
+		return zapcore.WarnLevel
+	case env.LevelError:
+		return zapcore.ErrorLevel
+	case env.LevelFatal:
+		return zapcore.FatalLevel
+	}
+
+	return zapcore.InfoLevel
+}
+
+// GetLevel returns the env var as a zapcore.Level, see env.GetLogLevel
+func GetLevel(key string) (zapcore.Level, error) {
+	l, err := env.GetLogLevel(key)
+	if err != nil {
+		return zapcore.InfoLevel, err
+	}
+
+	return Level(l), nil
+}
+
+// SetLevel sets the level to the env var, it is left as it is when the env var is not set or not a level
+func SetLevel(level zap.AtomicLevel, key string) error {
+	l, err := GetLevel(key)
+	if err != nil {
+		return err
+	}
+	level.SetLevel(l)
+
+	return nil
+}
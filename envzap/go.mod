@@ -0,0 +1,13 @@
+module github.com/andreGarvin/env/envzap
+
+go 1.17
+
+require (
+	github.com/andreGarvin/env v0.0.0-00010101000000-000000000000
+	go.uber.org/zap v1.21.0
+)
+
+require (
+	go.uber.org/atomic v1.7.0 // indirect
+	go.uber.org/multierr v1.6.0 // indirect
+)
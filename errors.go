@@ -0,0 +1,146 @@
+package env
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrIsDirectory is the Err of a FileError when a directory was given instead of a file
+	ErrIsDirectory = errors.New("is a directory")
+
+	// ErrEmptyFilename is the Err of a FileError when an empty filename was given
+	ErrEmptyFilename = errors.New("filename is empty")
+
+	// ErrURLFilename is the Err of a FileError when a URL was given instead of a file path
+	ErrURLFilename = errors.New("is a URL, not a file path")
+
+	// ErrOffline is the Err of an AdapterError for a Required adapter that needs the network, see WithOffline
+	ErrOffline = errors.New("adapter needs the network and the loader is offline")
+)
+
+// FileError is returned when a file given to Load can not be used, Suggestion says how to fix it if there is a known fix
+type FileError struct {
+	Filename   string
+	Err        error
+	Suggestion string
+}
+
+func (e *FileError) Error() string {
+	msg := fmt.Sprintf("could not load %q: %s", e.Filename, e.Err)
+	if e.Suggestion != "" {
+		msg += " (" + e.Suggestion + ")"
+	}
+
+	return msg
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// EnvironmentError is returned when the metadata header of a file is stamped for another environment than the current one
+type EnvironmentError struct {
+	Filename string
+	// File is the environment in the metadata header of the file
+	File string
+	// Current is the value of the environment key, APP_ENV by default
+	Current string
+}
+
+func (e *EnvironmentError) Error() string {
+	return fmt.Sprintf("refusing to load %s: it is for the %s environment, but this is %s", e.Filename, e.File, e.Current)
+}
+
+// SyntaxError is returned by ParseStrict with every line that does not parse
+type SyntaxError struct {
+	Errors []LineError
+}
+
+// LineError is a line that does not parse, Line counts from 1
+type LineError struct {
+	Line    int
+	Text    string
+	Message string
+}
+
+func (e LineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+func (e *SyntaxError) Error() string {
+	var lines []string
+	for _, err := range e.Errors {
+		lines = append(lines, err.Error())
+	}
+
+	return "invalid env file: " + strings.Join(lines, ", ")
+}
+
+// DuplicateError is returned with the FailOnDuplicate policy when a key is set again, First is the file that set it first
+type DuplicateError struct {
+	Key    string
+	First  string
+	Source string
+	// Line is the line of Source setting it again, 0 for YAML, JSON and TOML files
+	Line int
+}
+
+func (e *DuplicateError) Error() string {
+	again := e.Source
+	if e.Line != 0 {
+		again = fmt.Sprintf("line %d of %s", e.Line, e.Source)
+	}
+	if e.Source == e.First {
+		return fmt.Sprintf("%s is set twice in %s, again on line %d", e.Key, e.Source, e.Line)
+	}
+
+	return fmt.Sprintf("%s is set in %s and again on %s", e.Key, e.First, again)
+}
+
+// KeyError is returned with WithValidKeys for a key that is not a valid env var name
+type KeyError struct {
+	Key    string
+	Source string
+	// Line is the line of Source setting the key, 0 for adapters
+	Line int
+}
+
+func (e *KeyError) Error() string {
+	where := e.Source
+	if e.Line != 0 {
+		where = fmt.Sprintf("line %d of %s", e.Line, e.Source)
+	}
+
+	return fmt.Sprintf("invalid key %q on %s: keys are letters, digits and underscores and can not start with a digit", e.Key, where)
+}
+
+// DecodeError is returned by WithBase64 for a base64: value of Key that does not decode, Source is the file or adapter it came from
+type DecodeError struct {
+	Key    string
+	Source string
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("could not decode the base64 value of %s from %s: %s", e.Key, e.Source, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// AdapterError is returned when an adapter fails to pull, Adapter is its Name
+type AdapterError struct {
+	Adapter string
+	Err     error
+}
+
+func (e *AdapterError) Error() string {
+	return fmt.Sprintf("error occured running %s: %s", e.Adapter, e.Err)
+}
+
+func (e *AdapterError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,293 @@
+package env
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// maxExpandDepth is how deep references can chain (A -> B -> C...) before expanding gives up
+const maxExpandDepth = 64
+
+var (
+	// ErrReferenceCycle is the Err of an ExpandError when references loop back to a key being expanded
+	ErrReferenceCycle = errors.New("reference cycle")
+
+	// ErrReferenceTooDeep is the Err of an ExpandError when references chain deeper than 64 keys
+	ErrReferenceTooDeep = errors.New("references chain too deep")
+)
+
+// ExpandError is returned when a ${VAR} reference can not be expanded, Path is the chain of keys that lead to it
+type ExpandError struct {
+	Path []string
+	Err  error
+}
+
+func (e *ExpandError) Error() string {
+	return fmt.Sprintf("could not expand %s: %s", strings.Join(e.Path, " -> "), e.Err)
+}
+
+func (e *ExpandError) Unwrap() error {
+	return e.Err
+}
+
+// UndefinedError is returned in strict expand mode when values reference keys that are not set anywhere
+type UndefinedError struct {
+	References []Reference
+}
+
+// Reference is a ${Name} reference in the value of Key
+type Reference struct {
+	Key  string
+	Name string
+}
+
+func (e *UndefinedError) Error() string {
+	var refs []string
+	for _, ref := range e.References {
+		refs = append(refs, fmt.Sprintf("${%s} in %s", ref.Name, ref.Key))
+	}
+
+	return "undefined references: " + strings.Join(refs, ", ")
+}
+
+// expander resolves ${VAR} references, remembering what it resolved so every key is only expanded once
+type expander struct {
+	// raw returns the value of a key and if references in it should be expanded
+	raw   func(key string) (val string, found, expandable bool)
+	done  map[string]string
+	stack []string
+
+	// strict reports if references to keys that are not set in the value of key are collected
+	// instead of expanding to nothing
+	strict    func(key string) bool
+	undefined []Reference
+
+	// outer returns the value a key had before the one being expanded, so a key can reference itself
+	// like PATH=${PATH}:/extra. Without it a key referencing itself is a cycle
+	outer func(key string) (string, bool)
+}
+
+// expandMap expands the values in files, looking references up in adapters, then files and then the env.
+// Keys that adapters override are left alone since the adapter value wins anyway. rules returns if a
+// key is expanded and if it is strict, where references to keys that are not set are an *UndefinedError.
+// A key that references itself gets its value from outer
+func expandMap(files, adapters *Map, rules func(key string) fileSettings, outer func(key string) (string, bool)) error {
+	x := &expander{
+		raw: func(key string) (string, bool, bool) {
+			if val, ok := adapters.Map[key]; ok {
+				return val, true, false
+			}
+
+			if val, ok := files.Map[key]; ok {
+				// late bound @ref: values are expanded when they are read, see Lookup
+				return val, true, rules(key).expand && !files.isLiteral(key) && !strings.HasPrefix(val, RefPrefix)
+			}
+
+			val, ok := os.LookupEnv(key)
+			return val, ok, false
+		},
+		done: make(map[string]string),
+		strict: func(key string) bool {
+			return rules(key).strict
+		},
+		outer: outer,
+	}
+
+	// go through the keys in order so the same error is reported every time
+	var keys []string
+	for key := range files.Map {
+		if _, ok := adapters.Map[key]; !ok && rules(key).expand {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		val, _, err := x.resolve(key)
+		if err != nil {
+			return err
+		}
+
+		files.Set(key, val)
+	}
+
+	if len(x.undefined) != 0 {
+		return &UndefinedError{References: x.undefined}
+	}
+
+	return nil
+}
+
+// resolve returns the expanded value of the key
+func (x *expander) resolve(key string) (string, bool, error) {
+	if val, ok := x.done[key]; ok {
+		return val, true, nil
+	}
+
+	for i, k := range x.stack {
+		if k == key {
+			return "", false, &ExpandError{Path: append(append([]string(nil), x.stack[i:]...), key), Err: ErrReferenceCycle}
+		}
+	}
+
+	if len(x.stack) >= maxExpandDepth {
+		return "", false, &ExpandError{Path: append(append([]string(nil), x.stack...), key), Err: ErrReferenceTooDeep}
+	}
+
+	val, found, expandable := x.raw(key)
+	if !found || !expandable {
+		return val, found, nil
+	}
+
+	x.stack = append(x.stack, key)
+	val, err := x.expand(val)
+	x.stack = x.stack[:len(x.stack)-1]
+	if err != nil {
+		return "", false, err
+	}
+
+	x.done[key] = val
+	return val, true, nil
+}
+
+/*
+expand replaces the ${VAR} references in the value, references to keys that are not set expand to nothing.
+Like a POSIX shell it also understands
+
+	${VAR:-default}  default when VAR is not set or empty, ${VAR-default} only when it is not set
+	${VAR:?message}  fails with the message when VAR is not set or empty, ${VAR?message} only when it is not set
+
+The default can itself hold references. A dollar sign can be escaped as \$ or $$ to keep it, so
+\${VAR} and $${VAR} are left as ${VAR}
+*/
+func (x *expander) expand(val string) (string, error) {
+	var out strings.Builder
+
+	for i := 0; i < len(val); i++ {
+		c := val[i]
+
+		// escaped dollar signs
+		if (c == '\\' || c == '$') && i+1 < len(val) && val[i+1] == '$' {
+			out.WriteByte('$')
+			i++
+			continue
+		}
+
+		if c != '$' || i+1 == len(val) || val[i+1] != '{' {
+			out.WriteByte(c)
+			continue
+		}
+
+		end := closingBrace(val, i+2)
+		if end == -1 {
+			out.WriteString(val[i:])
+			break
+		}
+
+		name, op, word := splitReference(val[i+2 : end])
+		if !isName(name) || (op == "" && word != "") {
+			// not a reference, keep it as it is
+			out.WriteString(val[i : end+1])
+			i = end
+			continue
+		}
+
+		ref, found, err := x.reference(name)
+		if err != nil {
+			return "", err
+		}
+
+		// the colon forms treat an empty value like one that is not set
+		missing := !found || (ref == "" && strings.HasPrefix(op, ":"))
+
+		switch {
+		case missing && strings.HasSuffix(op, "-"):
+			ref, err = x.expand(word)
+			if err != nil {
+				return "", err
+			}
+		case missing && strings.HasSuffix(op, "?"):
+			if word == "" {
+				word = "is not set"
+			}
+			return "", &ExpandError{Path: append(append([]string(nil), x.stack...), name), Err: errors.New(word)}
+		case !found:
+			if key := x.stack[len(x.stack)-1]; x.strict(key) {
+				x.undefined = append(x.undefined, Reference{Key: key, Name: name})
+			}
+		}
+
+		out.WriteString(ref)
+		i = end
+	}
+
+	return out.String(), nil
+}
+
+// reference returns the value of a reference, one to the key being expanded is looked up with outer
+func (x *expander) reference(name string) (string, bool, error) {
+	if x.outer != nil && len(x.stack) != 0 && x.stack[len(x.stack)-1] == name {
+		val, ok := x.outer(name)
+		return val, ok, nil
+	}
+
+	return x.resolve(name)
+}
+
+// closingBrace returns the index of the } closing the reference starting at start, skipping nested references
+func closingBrace(val string, start int) int {
+	depth := 0
+
+	for i := start; i < len(val); i++ {
+		switch {
+		case val[i] == '$' && i+1 < len(val) && val[i+1] == '{':
+			depth++
+			i++
+		case val[i] == '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+
+	return -1
+}
+
+// splitReference splits what is between the braces of a reference into the name, the :-, -, :? or ? operator and its word
+func splitReference(ref string) (name, op, word string) {
+	i := 0
+	for i < len(ref) && ref[i] != ':' && ref[i] != '-' && ref[i] != '?' {
+		i++
+	}
+	name, rest := ref[:i], ref[i:]
+
+	for _, op := range []string{":-", ":?", "-", "?"} {
+		if strings.HasPrefix(rest, op) {
+			return name, op, rest[len(op):]
+		}
+	}
+
+	return name, "", rest
+}
+
+// isName reports if s is a valid env var name: letters, digits and underscores, not starting with a digit
+func isName(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i, r := range s {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,225 @@
+package env
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeEnvFile writes the content to a .env file in a new temp dir, removed when the test is done
+func writeEnvFile(t testing.TB, content string) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, ".env")
+	err = ioutil.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestExpand(t *testing.T) {
+	os.Setenv("ENV_TEST_OUTER", "outer")
+	defer os.Unsetenv("ENV_TEST_OUTER")
+
+	tests := []struct {
+		name    string
+		content string
+		want    EnvMap
+	}{
+		{"reference", "A=1\nB=${A}", EnvMap{"A": "1", "B": "1"}},
+		{"twice", "A=1\nB=${A}${A}", EnvMap{"A": "1", "B": "11"}},
+		{"from the env", "B=${ENV_TEST_OUTER}", EnvMap{"B": "outer"}},
+		{"not set", "B=${NOT_SET}", EnvMap{"B": ""}},
+		{"bare dollar is kept", "B=$A", EnvMap{"B": "$A"}},
+		{"unclosed is kept", "A=1\nB=${A", EnvMap{"A": "1", "B": "${A"}},
+
+		// escapes, with and without quotes
+		{"backslash escape", `A=1` + "\n" + `B=\${A}`, EnvMap{"A": "1", "B": "${A}"}},
+		{"dollar escape", "A=1\nB=$${A}", EnvMap{"A": "1", "B": "${A}"}},
+		{"dollar escape in text", "P=price $$5", EnvMap{"P": "price $5"}},
+		{"double quotes expand", `A=1` + "\n" + `B="${A}"`, EnvMap{"A": "1", "B": "1"}},
+		{"backslash escape in double quotes", `A=1` + "\n" + `B="\${A}"`, EnvMap{"A": "1", "B": "${A}"}},
+		{"dollar escape in double quotes", `A=1` + "\n" + `B="$${A}"`, EnvMap{"A": "1", "B": "${A}"}},
+		{"single quotes do not expand", `A=1` + "\n" + `B='${A}'`, EnvMap{"A": "1", "B": "${A}"}},
+		{"single quotes keep escapes", `A=1` + "\n" + `B='\${A}'`, EnvMap{"A": "1", "B": `\${A}`}},
+
+		// a key referencing itself gets the value from the env
+		{"self reference", "ENV_TEST_OUTER=${ENV_TEST_OUTER}:extra", EnvMap{"ENV_TEST_OUTER": "outer:extra"}},
+		{"self reference not set", "A=${A}", EnvMap{"A": ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewLoader(WithExpand()).Read(writeEnvFile(t, tt.content))
+			if err != nil {
+				t.Fatalf("Read(%q) failed: %s", tt.content, err)
+			}
+
+			if !reflect.DeepEqual(got.Map, tt.want) {
+				t.Errorf("Read(%q) = %q, want %q", tt.content, got.Map, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandCycles(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     error
+		message string
+	}{
+		{"cycle", "A=${B}\nB=${A}", ErrReferenceCycle, "could not expand A -> B -> A: reference cycle"},
+		{"longer cycle", "A=${B}\nB=${C}\nC=${A}", ErrReferenceCycle, "could not expand A -> B -> C -> A: reference cycle"},
+		{"cycle in a default", "A=${NOT_SET:-${B}}\nB=${A}", ErrReferenceCycle, "could not expand A -> B -> A: reference cycle"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLoader(WithExpand()).Read(writeEnvFile(t, tt.content))
+			if err == nil {
+				t.Fatalf("Read(%q) did not fail", tt.content)
+			}
+
+			var expandErr *ExpandError
+			if !errors.As(err, &expandErr) {
+				t.Fatalf("Read(%q) error = %T, want an *ExpandError", tt.content, err)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("Read(%q) error = %v, want %v", tt.content, err, tt.err)
+			}
+			if !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("Read(%q) error = %q, want it to contain %q", tt.content, err, tt.message)
+			}
+		})
+	}
+}
+
+func TestExpandDefaults(t *testing.T) {
+	os.Setenv("ENV_TEST_OUTER", "outer")
+	defer os.Unsetenv("ENV_TEST_OUTER")
+
+	tests := []struct {
+		name    string
+		content string
+		want    EnvMap
+	}{
+		{"default", "B=${NOT_SET:-d}", EnvMap{"B": "d"}},
+		{"default when empty", "A=\nB=${A:-d}", EnvMap{"A": "", "B": "d"}},
+		{"default only when not set", "A=\nB=${A-d}", EnvMap{"A": "", "B": ""}},
+		{"default not used", "A=1\nB=${A:-d}", EnvMap{"A": "1", "B": "1"}},
+		{"nested default", "B=${NOT_SET:-${ENV_TEST_OUTER}}", EnvMap{"B": "outer"}},
+		{"required is set", "A=1\nB=${A:?need it}", EnvMap{"A": "1", "B": "1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewLoader(WithExpand()).Read(writeEnvFile(t, tt.content))
+			if err != nil {
+				t.Fatalf("Read(%q) failed: %s", tt.content, err)
+			}
+
+			if !reflect.DeepEqual(got.Map, tt.want) {
+				t.Errorf("Read(%q) = %q, want %q", tt.content, got.Map, tt.want)
+			}
+		})
+	}
+
+	// ${VAR:?message} fails the load with the message when VAR is not set or empty
+	for _, content := range []string{"B=${NOT_SET:?need it}", "A=\nB=${A:?need it}"} {
+		_, err := NewLoader(WithExpand()).Read(writeEnvFile(t, content))
+
+		var expandErr *ExpandError
+		if !errors.As(err, &expandErr) || !strings.Contains(err.Error(), "need it") {
+			t.Errorf("Read(%q) error = %v, want an *ExpandError with the message", content, err)
+		}
+	}
+}
+
+func TestStrictExpand(t *testing.T) {
+	os.Setenv("ENV_TEST_OUTER", "outer")
+	defer os.Unsetenv("ENV_TEST_OUTER")
+
+	// the env, other keys and defaults all define a reference, so only A and C are reported
+	content := "A=${NOT_SET}\nB=${ENV_TEST_OUTER}\nC=x ${ALSO_NOT_SET}\nD=${A}\nE=${NOT_SET:-d}\nF=\nG=${F}"
+	_, err := NewLoader(WithStrictExpand()).Read(writeEnvFile(t, content))
+
+	var undefined *UndefinedError
+	if !errors.As(err, &undefined) {
+		t.Fatalf("Read(%q) error = %v, want an *UndefinedError", content, err)
+	}
+
+	want := []Reference{{Key: "A", Name: "NOT_SET"}, {Key: "C", Name: "ALSO_NOT_SET"}}
+	if !reflect.DeepEqual(undefined.References, want) {
+		t.Errorf("Read(%q) undefined references = %+v, want %+v", content, undefined.References, want)
+	}
+
+	got, err := NewLoader(WithStrictExpand()).Read(writeEnvFile(t, "A=1\nB=${A}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Map["B"] != "1" {
+		t.Errorf("B = %q, want 1", got.Map["B"])
+	}
+}
+
+func TestExpandSelfReferenceReload(t *testing.T) {
+	os.Setenv("ENV_TEST_PATH", "/bin")
+	defer os.Unsetenv("ENV_TEST_PATH")
+
+	path := writeEnvFile(t, "ENV_TEST_PATH=${ENV_TEST_PATH}:/extra")
+	l := NewLoader(WithExpand())
+
+	// loading again expands from the value before the loader set it, so /extra is only added once
+	for i := 0; i < 2; i++ {
+		err := l.Overload(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := os.Getenv("ENV_TEST_PATH"); got != "/bin:/extra" {
+			t.Fatalf("load %d: ENV_TEST_PATH = %q, want /bin:/extra", i+1, got)
+		}
+	}
+}
+
+func TestExpandSelfReferenceFailedLoad(t *testing.T) {
+	os.Setenv("ENV_TEST_PATH", "/bin")
+	defer os.Unsetenv("ENV_TEST_PATH")
+
+	path := writeEnvFile(t, "ENV_TEST_PATH=${ENV_TEST_PATH}:/extra")
+	l := NewLoader(WithExpand())
+	if err := l.Overload(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// a load that sets nothing does not change what the loader remembers from before it set the key
+	os.Setenv("ENV_TEST_PATH", "/usr")
+	err := l.Overload(writeEnvFile(t, "ENV_TEST_PATH=${ENV_TEST_PATH}:/extra\nBAD=a\x00b"))
+	if err == nil {
+		t.Fatal("loading a value with a NUL character did not fail")
+	}
+	if got := os.Getenv("ENV_TEST_PATH"); got != "/usr" {
+		t.Fatalf("the failed load set ENV_TEST_PATH to %q", got)
+	}
+
+	os.Setenv("ENV_TEST_PATH", "/bin:/extra")
+	if err := l.Overload(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := os.Getenv("ENV_TEST_PATH"); got != "/bin:/extra" {
+		t.Errorf("ENV_TEST_PATH = %q, want /bin:/extra", got)
+	}
+}
@@ -0,0 +1,43 @@
+package env
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+/*
+FilePrefix marks a value that is the path of a file holding the real value, WithFileRefs reads the
+file when the env is loaded
+
+	TLS_KEY=file:///run/secrets/tls.key
+
+That is how Docker and Kubernetes mount secrets. A relative path like file://certs/tls.key is
+relative to the working directory, and one trailing line break is trimmed from the contents
+*/
+const FilePrefix = "file://"
+
+// readFileRefs replaces the file:// values in emap with the contents of the files, the contents are literal so they are not expanded
+func readFileRefs(emap *Map) error {
+	for _, key := range emap.Ordered() {
+		val := emap.Map[key]
+		if !strings.HasPrefix(val, FilePrefix) {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(strings.TrimPrefix(val, FilePrefix))
+		if err != nil {
+			return fmt.Errorf("could not read the file:// value of %s from %s: %s", key, emap.Source(key), err)
+		}
+
+		contents := string(data)
+		if strings.HasSuffix(contents, "\n") {
+			contents = strings.TrimSuffix(strings.TrimSuffix(contents, "\n"), "\r")
+		}
+
+		emap.Set(key, contents)
+		emap.keyInfo(key).literal = true
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package env
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// fileFormats parse config files that are not env files, by their extension
+var fileFormats = map[string]func(data []byte, sep string) (*Map, error){
+	".yaml": parseYAML,
+	".yml":  parseYAML,
+	".json": parseJSON,
+	".toml": parseTOML,
+
+	".properties": parseProperties,
+}
+
+// fileFormat returns the parser for the file by its extension, the .local shadow of a file has the same format
+func fileFormat(filename string) func(data []byte, sep string) (*Map, error) {
+	return fileFormats[strings.ToLower(filepath.Ext(strings.TrimSuffix(filename, ".local")))]
+}
+
+// parseFormat parses a config file with its format on top of emap
+func parseFormat(cfg settings, emap *Map, r io.Reader, filename string, format func(data []byte, sep string) (*Map, error)) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return &FileError{Filename: filename, Err: err}
+	}
+
+	fmap, err := format(bytes.TrimPrefix(data, []byte(bom)), cfg.nestedSep)
+	if err != nil {
+		return &FileError{Filename: filename, Err: err}
+	}
+
+	for _, key := range fmap.Keys() {
+		skip, err := cfg.parser.duplicate(emap, key, filename, 0)
+		if err != nil {
+			return err
+		}
+		if skip {
+			continue
+		}
+
+		emap.Set(key, fmap.Map[key])
+		emap.SetSource(key, filename)
+	}
+
+	return nil
+}
+
+// nestedKey joins the keys of a nested value into an env var name, upper cased with - and . made underscores
+func nestedKey(path []string, sep string) (string, error) {
+	key := strings.ToUpper(strings.Join(path, sep))
+	key = strings.NewReplacer("-", "_", ".", "_", " ", "_").Replace(key)
+
+	if !isName(key) {
+		return "", fmt.Errorf("%q is not a valid env var name", key)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,108 @@
+//go:build go1.18
+// +build go1.18
+
+package env
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/joho/godotenv"
+)
+
+// fuzzSeeds are the seed corpus of the fuzzers, the cases the parser is known to get right
+var fuzzSeeds = []string{
+	"A=1",
+	"export A=1\nB=2",
+	"# comment\nA=1\n\nB=2\n",
+	"A='single quoted $B'",
+	`A="double \"quoted\"\nwith a line break"`,
+	"A=value # inline comment",
+	"A=\"multi\nline\"",
+	"A=1\r\nB=2\r\n",
+	"A=<<EOF\nheredoc\nEOF",
+	"A?=1\nA+=2",
+	"A=continued \\\nline",
+}
+
+/*
+FuzzParse checks what the parser promises for any content: it does not panic, ParseStrict parses
+the same keys when it finds nothing wrong, and writing the map back out parses to the same map
+
+	go test -fuzz FuzzParse
+*/
+func FuzzParse(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, content string) {
+		emap := Parse(content)
+
+		strict, err := ParseStrict(content)
+		if err == nil && !reflect.DeepEqual(strict.Map, emap.Map) {
+			t.Fatalf("ParseStrict(%q) = %q, Parse = %q", content, strict.Map, emap.Map)
+		}
+
+		var buf bytes.Buffer
+		if _, err := emap.WriteTo(&buf); err != nil {
+			return
+		}
+
+		again := Parse(buf.String())
+		if !reflect.DeepEqual(again.Map, emap.Map) {
+			t.Fatalf("%q parsed to %q, written as %q it parses to %q", content, emap.Map, buf.String(), again.Map)
+		}
+	})
+}
+
+/*
+FuzzGodotenv parses the content with DialectGodotenv and with github.com/joho/godotenv and fails
+where they differ, to find what the dialect does not match yet
+
+	go test -fuzz FuzzGodotenv
+
+Content is skipped where the two are known to differ on purpose
+
+  - a $ that is not escaped as \$, godotenv expands while parsing and Dialect.Parse does not
+  - \\$, which godotenv unescapes twice
+  - a backslash before a quote, godotenv never closes a quote after a backslash (even an escaped
+    one) and cuts escaped quotes off the ends of the value
+  - a \r that does not end a line, godotenv splits lines at it
+  - content that is not UTF-8, this package keeps the bytes as they are
+  - content godotenv fails on or reads a line without a = from, this package skips those lines
+*/
+func FuzzGodotenv(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		if !strings.Contains(seed, "?=") && !strings.Contains(seed, "\\\n") && !strings.Contains(seed, "<<") {
+			f.Add(seed)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, content string) {
+		if strings.Count(content, "$") != strings.Count(content, `\$`) || strings.Contains(content, `\\$`) || !utf8.ValidString(content) {
+			t.Skip()
+		}
+		if strings.Contains(content, `\"`) || strings.Contains(content, `\'`) || strings.Contains(strings.Replace(content, "\r\n", "\n", -1), "\r") {
+			t.Skip()
+		}
+
+		want, err := godotenv.Unmarshal(content)
+		if err != nil {
+			t.Skip()
+		}
+
+		// godotenv takes a line without a = as the value of an empty key, this package skips it
+		if _, ok := want[""]; ok {
+			t.Skip()
+		}
+
+		got := DialectGodotenv.Parse(content).Map
+		if !reflect.DeepEqual(map[string]string(got), want) {
+			t.Errorf("%q parses to %q, godotenv parses it to %q", content, got, want)
+		}
+	})
+}
@@ -0,0 +1,117 @@
+package env
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var (
+	// ErrNotGenerated is returned by CheckGenerated for content without the header WriteGenerated writes
+	ErrNotGenerated = errors.New("content has no generated header")
+
+	// ErrGeneratedChanged is returned by CheckGenerated when the content does not match the hash in its header
+	ErrGeneratedChanged = errors.New("content was changed after it was generated")
+)
+
+// generatedPrefix starts the first line of the header, after the comment marker
+const generatedPrefix = "Generated by env from "
+
+/*
+WriteGenerated writes the map like WriteFormat with a header comment on top, naming the files and
+adapters the keys came from and a hash of what follows, so a generated file can be traced back
+
+	# Generated by env from .env, .env.local and vault, do not edit
+	# sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+
+CheckGenerated checks a file still matches its hash. JSON can not hold comments, so only env files
+and JavaScript modules can have a header
+*/
+func (e *Map) WriteGenerated(w io.Writer, format OutputFormat) error {
+	var comment string
+	switch format {
+	case OutputEnv:
+		comment = "# "
+	case OutputModule:
+		comment = "// "
+	case OutputJSON, OutputDefine:
+		return fmt.Errorf("%s output can not have a generated header, it has no comments", format)
+	default:
+		return e.WriteFormat(w, format)
+	}
+
+	var body bytes.Buffer
+	err := e.WriteFormat(&body, format)
+	if err != nil {
+		return err
+	}
+
+	sources := "nothing"
+	if names := e.sources(); len(names) != 0 {
+		sources = joinAnd(names)
+	}
+
+	header := fmt.Sprintf("%s%s%s, do not edit\n%ssha256:%s\n", comment, generatedPrefix, sources, comment, contentHash(body.Bytes()))
+
+	_, err = io.WriteString(w, header+body.String())
+	return err
+}
+
+// CheckGenerated returns ErrGeneratedChanged if content written by WriteGenerated was edited since, and
+// ErrNotGenerated if it has no header
+func CheckGenerated(content []byte) error {
+	text := strings.ReplaceAll(string(content), "\r\n", "\n")
+
+	lines := strings.SplitN(text, "\n", 3)
+	if len(lines) < 3 {
+		return ErrNotGenerated
+	}
+
+	var comment string
+	for _, prefix := range []string{"# ", "// "} {
+		if strings.HasPrefix(lines[0], prefix+generatedPrefix) {
+			comment = prefix
+		}
+	}
+	if comment == "" || !strings.HasPrefix(lines[1], comment+"sha256:") {
+		return ErrNotGenerated
+	}
+
+	if strings.TrimPrefix(lines[1], comment+"sha256:") != contentHash([]byte(lines[2])) {
+		return ErrGeneratedChanged
+	}
+
+	return nil
+}
+
+// sources returns where the keys came from, in the order the keys were set
+func (e *Map) sources() []string {
+	var names []string
+
+	for _, key := range e.Ordered() {
+		if source := e.Source(key); source != "" && !hasString(names, source) {
+			names = append(names, source)
+		}
+	}
+
+	return names
+}
+
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// joinAnd joins a, b and c like that
+func joinAnd(items []string) string {
+	if len(items) == 1 {
+		return items[0]
+	}
+
+	return strings.Join(items[:len(items)-1], ", ") + " and " + items[len(items)-1]
+}
@@ -0,0 +1,394 @@
+package env
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+RefPrefix marks a value whose ${VAR} references are resolved every time it is read instead of once
+when it is loaded, so it follows the keys it references when they change
+
+	ADDR=@ref:${HOST}:${PORT}
+
+Reading ADDR with Get, Lookup or one of the typed getters returns the current HOST and PORT.
+Reading it with os.Getenv returns it as it is written
+*/
+const RefPrefix = "@ref:"
+
+// Get returns the value of the env var, resolving @ref: values (see RefPrefix). It is empty if the
+// env var is not set or its references can not be resolved, use Lookup to tell them apart
+func Get(key string) string {
+	val, _, _ := Lookup(key)
+
+	return val
+}
+
+// Lookup returns the value of the env var and if it is set, resolving @ref: values (see RefPrefix)
+func Lookup(key string) (string, bool, error) {
+	return LookupIn(Environ(), key)
+}
+
+// LookupIn is Lookup on a Reader, @ref: values are resolved against the Reader
+func LookupIn(r Reader, key string) (string, bool, error) {
+	x := &expander{
+		raw: func(key string) (string, bool, bool) {
+			val, ok := r.Lookup(key)
+			if strings.HasPrefix(val, RefPrefix) {
+				return strings.TrimPrefix(val, RefPrefix), ok, true
+			}
+
+			return val, ok, false
+		},
+		done:   make(map[string]string),
+		strict: func(string) bool { return false },
+	}
+
+	val, ok, err := x.resolve(key)
+	if err != nil {
+		return "", true, err
+	}
+
+	return val, ok, nil
+}
+
+// lookupSet is Lookup where an env var that is not set is an error, for the typed getters
+func lookupSet(key string) (string, error) {
+	val, ok, err := Lookup(key)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("%s is not set", key)
+	}
+
+	return val, nil
+}
+
+// GetInt returns the env var as an int
+func GetInt(key string) (int, error) {
+	val, err := lookupSet(key)
+	if err != nil {
+		return 0, err
+	}
+
+	i, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not an int: %q", key, val)
+	}
+
+	return i, nil
+}
+
+// GetBool returns the env var as a bool
+func GetBool(key string) (bool, error) {
+	val, err := lookupSet(key)
+	if err != nil {
+		return false, err
+	}
+
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, fmt.Errorf("%s is not a bool: %q", key, val)
+	}
+
+	return b, nil
+}
+
+// GetFloat returns the env var as a float64
+func GetFloat(key string) (float64, error) {
+	val, err := lookupSet(key)
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a number: %q", key, val)
+	}
+
+	return f, nil
+}
+
+// GetDuration returns the env var as a time.Duration, like 1m30s
+func GetDuration(key string) (time.Duration, error) {
+	val, err := lookupSet(key)
+	if err != nil {
+		return 0, err
+	}
+
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a duration: %q", key, val)
+	}
+
+	return d, nil
+}
+
+// GetDurations returns the env var as a list of durations split by sep, like 100ms,1s,5s for retry backoffs
+func GetDurations(key, sep string) ([]time.Duration, error) {
+	val, err := lookupSet(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var durations []time.Duration
+	for _, part := range splitList(val, sep) {
+		d, err := time.ParseDuration(part)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a list of durations: %q is not a duration", key, part)
+		}
+		durations = append(durations, d)
+	}
+
+	return durations, nil
+}
+
+// GetCron returns the env var after checking it is a cron schedule, like `*/15 9-17 * * mon-fri` or @daily
+func GetCron(key string) (string, error) {
+	val, err := lookupSet(key)
+	if err != nil {
+		return "", err
+	}
+
+	err = checkCron(val)
+	if err != nil {
+		return "", fmt.Errorf("%s is not a cron schedule: %s", key, err)
+	}
+
+	return strings.TrimSpace(val), nil
+}
+
+/*
+GetPercent returns the env var as a ratio between 0 and 1, for rollouts and sampling rates. 25% and
+25 are both 0.25, and so is 0.25: a number without a % sign is a ratio when it is at most 1 and a
+percent otherwise, so write 1% for one percent
+*/
+func GetPercent(key string) (float64, error) {
+	val, err := lookupSet(key)
+	if err != nil {
+		return 0, err
+	}
+
+	s := strings.TrimSpace(val)
+	percent := strings.HasSuffix(s, "%")
+
+	f, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(s, "%")), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a percent: %q", key, val)
+	}
+
+	if percent || f > 1 {
+		f /= 100
+	}
+
+	if f < 0 || f > 1 || math.IsNaN(f) {
+		return 0, fmt.Errorf("%s is not between 0%% and 100%%: %q", key, val)
+	}
+
+	return f, nil
+}
+
+// GetRegexp returns the env var compiled as a regular expression
+func GetRegexp(key string) (*regexp.Regexp, error) {
+	val, err := lookupSet(key)
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := regexp.Compile(val)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a regular expression: %s", key, err)
+	}
+
+	return re, nil
+}
+
+// Glob is a glob pattern like *.example.com, matched with the rules of path.Match
+type Glob string
+
+// Match reports if name matches the pattern
+func (g Glob) Match(name string) bool {
+	ok, _ := path.Match(string(g), name)
+	return ok
+}
+
+// GetGlob returns the env var as a Glob after checking the pattern is valid
+func GetGlob(key string) (Glob, error) {
+	val, err := lookupSet(key)
+	if err != nil {
+		return "", err
+	}
+
+	// path.Match only reports a bad pattern when it gets that far in a name
+	_, err = path.Match(val, "")
+	if err != nil {
+		return "", fmt.Errorf("%s is not a glob pattern: %q", key, val)
+	}
+
+	return Glob(val), nil
+}
+
+// GetJSON unmarshals the JSON in the env var into target, which is a pointer to a struct, map or slice
+func GetJSON(key string, target interface{}) error {
+	val, err := lookupSet(key)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal([]byte(val), target)
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("%s is not valid JSON: %s at offset %d", key, syntaxErr, syntaxErr.Offset)
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return fmt.Errorf("%s does not fit: %s is a JSON %s, expected %s", key, typeErr.Field, typeErr.Value, typeErr.Type)
+	case err != nil:
+		return fmt.Errorf("could not read %s as JSON: %s", key, err)
+	}
+
+	return nil
+}
+
+/*
+GetStringSlice returns the env var split on commas, with the quoting rules of CSV (RFC 4180) so an
+item can hold a comma: `"a,b",c` is the two items a,b and c. Space around items is trimmed and an
+empty env var is an empty list
+*/
+func GetStringSlice(key string) ([]string, error) {
+	val, err := lookupSet(key)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := parseList(val)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a list: %s", key, err)
+	}
+
+	return items, nil
+}
+
+// parseList splits the list the way GetStringSlice does
+func parseList(val string) ([]string, error) {
+	if strings.TrimSpace(val) == "" {
+		return []string{}, nil
+	}
+
+	r := csv.NewReader(strings.NewReader(val))
+	r.TrimLeadingSpace = true
+
+	items, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Read(); err != io.EOF {
+		return nil, errors.New("line breaks must be inside quotes")
+	}
+
+	for i, item := range items {
+		items[i] = strings.TrimSpace(item)
+	}
+
+	return items, nil
+}
+
+// joinList joins the items with commas so parseList reads them back, the items with a comma, a quote
+// or a line break are quoted
+func joinList(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		if strings.ContainsAny(item, ",\"\r\n") {
+			item = `"` + strings.Replace(item, `"`, `""`, -1) + `"`
+		}
+		quoted[i] = item
+	}
+
+	return strings.Join(quoted, ",")
+}
+
+// GetSlice returns the env var split by sep with the space around items trimmed, like
+// ALLOWED_ORIGINS=a.com, b.com. Empty items are dropped, see GetStringSlice for quoted items
+func GetSlice(key, sep string) ([]string, error) {
+	val, err := lookupSet(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return splitList(val, sep), nil
+}
+
+// GetSlice returns the value of the key split by sep like the package level GetSlice, nil if the key is not in the map
+func (e *Map) GetSlice(key, sep string) []string {
+	val, ok := e.Map[key]
+	if !ok {
+		return nil
+	}
+
+	return splitList(val, sep)
+}
+
+// splitList splits val by sep, trimming the items and dropping the empty ones so a trailing sep does not add one
+func splitList(val, sep string) []string {
+	items := []string{}
+	for _, item := range strings.Split(val, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// Weighted is an item of a weighted list, see GetWeightedList
+type Weighted struct {
+	Key    string
+	Weight int
+}
+
+/*
+GetWeightedList returns the env var as a list of weighted items in the order they are written, like
+hostA=3,hostB=1 for load balancing. An item without a weight has a weight of 1 and weights can not
+be negative
+*/
+func GetWeightedList(key string) ([]Weighted, error) {
+	items, err := GetStringSlice(key)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]Weighted, 0, len(items))
+	for _, item := range items {
+		if item == "" {
+			continue
+		}
+
+		w := Weighted{Key: item, Weight: 1}
+		if i := strings.LastIndexByte(item, '='); i != -1 {
+			weight, err := strconv.Atoi(strings.TrimSpace(item[i+1:]))
+			if err != nil || weight < 0 {
+				return nil, fmt.Errorf("%s has an invalid weight in %q, expected key=weight", key, item)
+			}
+
+			w = Weighted{Key: strings.TrimSpace(item[:i]), Weight: weight}
+		}
+
+		if w.Key == "" {
+			return nil, fmt.Errorf("%s has an item without a key: %q", key, item)
+		}
+		list = append(list, w)
+	}
+
+	return list, nil
+}
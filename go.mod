@@ -0,0 +1,5 @@
+module github.com/andreGarvin/env
+
+go 1.14
+
+require github.com/joho/godotenv v1.5.1
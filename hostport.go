@@ -0,0 +1,113 @@
+package env
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// HostPort is an address split into its host and port, see GetHostPort
+type HostPort struct {
+	// Host is a hostname or an IP, IPv6 addresses are kept without their brackets
+	Host string
+	Port int
+}
+
+// String joins the host and port back into an address, putting IPv6 hosts in brackets
+func (h HostPort) String() string {
+	return net.JoinHostPort(h.Host, strconv.Itoa(h.Port))
+}
+
+/*
+GetHostPort returns the env var as a host:port address, like localhost:8080 or [::1]:8080. The host
+can be left out (:8080) to listen on every interface, the port is a number from 0 to 65535
+*/
+func GetHostPort(key string) (HostPort, error) {
+	val, err := lookupSet(key)
+	if err != nil {
+		return HostPort{}, err
+	}
+
+	addr, err := parseHostPort(strings.TrimSpace(val))
+	if err != nil {
+		return HostPort{}, fmt.Errorf("%s is not a host:port address: %s", key, err)
+	}
+
+	return addr, nil
+}
+
+/*
+GetHostPorts returns the env var as a comma separated list of host:port addresses, like the brokers
+of a Kafka cluster. Every address needs a host
+*/
+func GetHostPorts(key string) ([]HostPort, error) {
+	items, err := GetStringSlice(key)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make([]HostPort, 0, len(items))
+	for _, item := range items {
+		if item == "" {
+			continue
+		}
+
+		addr, err := parseHostPort(item)
+		if err == nil && addr.Host == "" {
+			err = fmt.Errorf("%q has no host", item)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a list of host:port addresses: %s", key, err)
+		}
+
+		addrs = append(addrs, addr)
+	}
+
+	return addrs, nil
+}
+
+// parseHostPort splits and checks a host:port address
+func parseHostPort(s string) (HostPort, error) {
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return HostPort{}, err
+	}
+
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 0 || n > 65535 {
+		return HostPort{}, fmt.Errorf("%q has an invalid port %q", s, port)
+	}
+
+	if !validHost(host) {
+		return HostPort{}, fmt.Errorf("%q has an invalid host %q", s, host)
+	}
+
+	return HostPort{Host: host, Port: n}, nil
+}
+
+// validHost reports if host is empty, an IP or a hostname. IPv6 addresses can have a %zone
+func validHost(host string) bool {
+	if host == "" || net.ParseIP(host) != nil {
+		return true
+	}
+
+	if i := strings.LastIndexByte(host, '%'); i != -1 && i != len(host)-1 {
+		return strings.Contains(host[:i], ":") && net.ParseIP(host[:i]) != nil
+	}
+	if strings.Contains(host, ":") {
+		return false
+	}
+
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+
+		if !isAlnum(strings.NewReplacer("-", "", "_", "").Replace(label)) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,103 @@
+package env
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+/*
+ParseJSON parses a JSON object into env vars, like the secrets.json files cloud consoles export.
+Nested objects are flattened by joining their keys with an underscore and every key is upper cased,
+so {"database": {"host": "localhost"}} sets DATABASE_HOST. Numbers and bools are kept as they are
+written, null is empty and arrays of values are joined with commas, an item with a comma, a quote or a
+line break is quoted the way GetStringSlice reads it
+*/
+func ParseJSON(data []byte) (*Map, error) {
+	return parseJSON(data, "_")
+}
+
+// parseJSON parses a JSON object, joining the keys of nested objects with sep
+func parseJSON(data []byte, sep string) (*Map, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var doc map[string]interface{}
+	err := dec.Decode(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid json: %s", err)
+	}
+
+	emap := NewMap()
+	err = flattenJSON(emap, nil, doc, sep)
+	if err != nil {
+		return nil, err
+	}
+
+	return emap, nil
+}
+
+// flattenJSON sets the values of the object in emap under path
+func flattenJSON(emap *Map, path []string, obj map[string]interface{}, sep string) error {
+	for name, v := range obj {
+		keyPath := append(append([]string(nil), path...), name)
+
+		if nested, ok := v.(map[string]interface{}); ok {
+			err := flattenJSON(emap, keyPath, nested, sep)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		key, err := nestedKey(keyPath, sep)
+		if err != nil {
+			return fmt.Errorf("invalid json: %s", err)
+		}
+
+		val, err := jsonValue(v)
+		if err != nil {
+			return fmt.Errorf("invalid json: %s %s", key, err)
+		}
+
+		emap.Set(key, val)
+	}
+
+	return nil
+}
+
+// jsonValue returns a JSON value as an env var value
+func jsonValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		if v {
+			return "true", nil
+		}
+		return "false", nil
+	case []interface{}:
+		var items []string
+		for _, item := range v {
+			if _, ok := item.([]interface{}); ok {
+				return "", fmt.Errorf("is a list of lists")
+			}
+			if _, ok := item.(map[string]interface{}); ok {
+				return "", fmt.Errorf("is a list of objects, only lists of values are supported")
+			}
+
+			val, err := jsonValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, val)
+		}
+		return joinList(items), nil
+	}
+
+	return "", fmt.Errorf("has an unsupported value")
+}
@@ -0,0 +1,87 @@
+package env
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// envLimits are roughly how much env the OS lets a process hand to the processes it starts
+type envLimits struct {
+	// perVar is the most bytes a single KEY=value can have, 0 for no limit
+	perVar int
+	// total is the most bytes the whole env can have, 0 for no limit
+	total int
+}
+
+func platformLimits() envLimits {
+	switch runtime.GOOS {
+	case "windows":
+		// 32,767 characters for a single variable, the env block itself has no limit anymore
+		return envLimits{perVar: 32767}
+	case "linux", "android":
+		// MAX_ARG_STRLEN for a single string, ARG_MAX with the default 8MB stack
+		return envLimits{perVar: 131072, total: 2097152}
+	case "darwin", "ios":
+		return envLimits{total: 1048576}
+	default:
+		// the BSDs use 256KB for ARG_MAX, it is the lowest of the common ones
+		return envLimits{total: 262144}
+	}
+}
+
+// SizeError is returned when loading would make the env bigger than the OS allows, since child processes
+// then fail to start with errors like "argument list too long"
+type SizeError struct {
+	// Key is the env var that is too big, or empty when it is the whole env
+	Key   string
+	Size  int
+	Limit int
+}
+
+func (e *SizeError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("the env would be %d bytes, over the %d byte limit on %s", e.Size, e.Limit, runtime.GOOS)
+	}
+
+	return fmt.Sprintf("env var %s is %d bytes, over the %d byte limit for a single env var on %s", e.Key, e.Size, e.Limit, runtime.GOOS)
+}
+
+// checkEnvSize checks the env vars and the env they would end up in fit in the platform limits,
+// warning through logf once the env is over three quarters of the limit
+func checkEnvSize(logf func(string, ...interface{}), target *Map) error {
+	limits := platformLimits()
+
+	total := 0
+	for _, kv := range os.Environ() {
+		total += len(kv) + 1
+	}
+
+	for _, key := range target.Ordered() {
+		val := target.Map[key]
+		size := len(key) + len(val) + 1
+
+		if limits.perVar != 0 && size > limits.perVar {
+			return &SizeError{Key: key, Size: size, Limit: limits.perVar}
+		}
+
+		if prev, ok := os.LookupEnv(key); ok {
+			total -= len(key) + len(prev) + 2
+		}
+		total += size + 1
+	}
+
+	if limits.total == 0 {
+		return nil
+	}
+
+	if total > limits.total {
+		return &SizeError{Size: total, Limit: limits.total}
+	}
+
+	if total > limits.total/4*3 {
+		logf("the env is %d bytes, close to the %d byte limit on %s", total, limits.total, runtime.GOOS)
+	}
+
+	return nil
+}
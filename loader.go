@@ -0,0 +1,944 @@
+package env
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrLoadedWithDifferentFiles is returned by LoadOnce when it is called again with other files than the first call
+var ErrLoadedWithDifferentFiles = errors.New("env was already loaded with different files")
+
+/*
+Loader holds the files, required keys and adapters used to load the env. The package level functions
+use a default Loader, libraries should create their own with NewLoader so they never touch the
+package level state that the application owns.
+
+A Loader is safe for concurrent use
+*/
+type Loader struct {
+	mu           sync.Mutex
+	settings     settings
+	requiredKeys []string
+	requiredTags []string
+	adapters     []*Adapter
+	hooks        []KeyHook
+	derived      []derivedKey
+	once         *loadOnce
+
+	// loaded is every key the Loader has set to the env, with the value it set and where it came from
+	loaded *Map
+
+	// prior is the value the keys the Loader set had in the env before it first set them, keys that
+	// were not set are not in it
+	prior map[string]string
+
+	// files is the files the last load read, at readAt
+	files  []string
+	readAt time.Time
+
+	// pulls is how the last pull of each adapter went, by name
+	pulls map[string]*adapterPull
+}
+
+type loadOnce struct {
+	sync.Once
+	filenames []string
+	err       error
+}
+
+// NewLoader creates a Loader, creating one does not do any I/O
+func NewLoader(opts ...Option) *Loader {
+	l := &Loader{
+		settings: defaultSettings(),
+		once:     &loadOnce{},
+	}
+
+	l.Configure(opts...)
+
+	return l
+}
+
+// Configure changes the options of the Loader, it only affects loads that start after it returns
+func (l *Loader) Configure(opts ...Option) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, opt := range opts {
+		opt(&l.settings)
+	}
+}
+
+// config returns a copy of the settings to use for one load
+func (l *Loader) config() settings {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.settings
+}
+
+// RequiredKeys adds keys that MustLoad and MustLoadSecrets check for
+func (l *Loader) RequiredKeys(keys []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.requiredKeys = append(l.requiredKeys, keys...)
+}
+
+// RequiredTagged makes MustLoad and MustLoadSecrets require every key that has one of the tags, in the
+// loaded files or in the schema (see WithSchema)
+func (l *Loader) RequiredTagged(tags ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.requiredTags = append(l.requiredTags, tags...)
+}
+
+/*
+KeyHook is called for every key as it is loaded, with the file or adapter it came from. It returns
+the key and value to use instead, which can be changed, or an empty key to drop it. An error stops the load
+*/
+type KeyHook func(key, val, source string) (string, string, error)
+
+// OnKey adds a hook that is called for every key of every file and adapter, hooks run in the order they were added
+func (l *Loader) OnKey(hook KeyHook) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.hooks = append(l.hooks, hook)
+}
+
+// runHooks passes the keys of emap that came from source through the hooks
+func (l *Loader) runHooks(emap *Map, source string) error {
+	l.mu.Lock()
+	hooks := append([]KeyHook(nil), l.hooks...)
+	l.mu.Unlock()
+
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	for _, key := range emap.Ordered() {
+		if emap.Source(key) != source {
+			continue
+		}
+
+		newKey, val := key, emap.Map[key]
+		for i, hook := range hooks {
+			var err error
+
+			newKey, val, err = runHook(hook, fmt.Sprintf("hook %d on %s from %s", i+1, key, source), newKey, val, source)
+			if _, ok := err.(*PanicError); ok {
+				return err
+			}
+			if err != nil {
+				return fmt.Errorf("hook failed on %s from %s: %s", key, source, err)
+			}
+			if newKey == "" {
+				break
+			}
+		}
+
+		if newKey != key {
+			// the info of the key moves with it to the new name
+			if info, ok := emap.info[key]; ok && newKey != "" {
+				emap.info[newKey] = info
+			}
+			delete(emap.info, key)
+			delete(emap.Map, key)
+		}
+
+		if newKey != "" {
+			emap.Set(newKey, val)
+		}
+	}
+
+	return nil
+}
+
+// runHook calls the hook, turning a panic into a *PanicError
+func runHook(hook KeyHook, name, key, val, source string) (newKey, newVal string, err error) {
+	defer recoverPanic(name, &err)
+
+	return hook(key, val, source)
+}
+
+type derivedKey struct {
+	key string
+	fn  func(m *Map) string
+}
+
+/*
+Derive adds a key that is computed from the others once every file and adapter is merged, so the
+logic composing it lives in one place
+
+	loader.Derive("DATABASE_URL", func(m *env.Map) string {
+		return fmt.Sprintf("postgres://%s@%s/%s", m.Map["DB_USER"], m.Map["DB_HOST"], m.Map["DB_NAME"])
+	})
+
+Derived keys are computed in the order they were added, so one can use the ones before it. They
+override a key of the same name from the files and adapters
+*/
+func (l *Loader) Derive(key string, fn func(m *Map) string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.derived = append(l.derived, derivedKey{key: key, fn: fn})
+}
+
+// derive sets the derived keys in the map, a func that panics fails the load with a *PanicError
+func (l *Loader) derive(emap *Map) error {
+	l.mu.Lock()
+	derived := append([]derivedKey(nil), l.derived...)
+	l.mu.Unlock()
+
+	for _, d := range derived {
+		err := deriveKey(d, emap)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func deriveKey(d derivedKey, emap *Map) (err error) {
+	defer recoverPanic("Derive of "+d.key, &err)
+
+	emap.Set(d.key, d.fn(emap))
+	emap.SetSource(d.key, "derived")
+
+	return nil
+}
+
+// ApplyAdapter adds adapters that are ran by Load and LoadSecrets
+func (l *Loader) ApplyAdapter(a ...*Adapter) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.adapters = append(l.adapters, a...)
+}
+
+/*
+Read parses the files and runs the adapters the same way Load does, but returns the merged map
+instead of setting it to the env
+*/
+func (l *Loader) Read(filenames ...string) (*Map, error) {
+	return l.read(NewMap(), nil, filenames)
+}
+
+// read is Read on top of the keys in globalEnvMap, which were read from the files in read
+func (l *Loader) read(globalEnvMap *Map, read []string, filenames []string) (*Map, error) {
+	cfg := l.config()
+
+	if len(filenames) == 0 {
+		filenames = cfg.filenames
+	}
+
+	// parse files, each one on top of the ones before it so ?= and += see them
+	for _, template := range filenames {
+		filename, ok, err := expandFilename(template)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			cfg.logf("could not load %s: it references env vars that are not set", template)
+			continue
+		}
+
+		// options given to File for the template apply to the file it expands to
+		if opts, ok := cfg.files[template]; ok && filename != template {
+			files := make(map[string][]FileOption, len(cfg.files)+1)
+			for name, fileOpts := range cfg.files {
+				files[name] = fileOpts
+			}
+			files[filename] = opts
+			cfg.files = files
+		}
+
+		names := []string{filename}
+
+		// the shadow file goes right on top of its file, unless it is loaded on its own anyway
+		if local := filename + ".local"; cfg.localFiles && !hasString(filenames, local) && exists(local) {
+			names = append(names, local)
+		}
+
+		for _, name := range names {
+			fileCfg := cfg
+			if name != filename {
+				// shadow files are there to override, whatever the duplicate policy
+				fileCfg.parser.duplicates = LastWins
+			}
+
+			ok, err := parseFile(fileCfg, globalEnvMap, name)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			read = append(read, name)
+
+			err = l.runHooks(globalEnvMap, name)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	globalEnvMap, err := l.resolve(cfg, globalEnvMap, true)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	l.files = read
+	l.readAt = time.Now()
+	l.mu.Unlock()
+
+	return globalEnvMap, nil
+}
+
+/*
+resolve runs what comes after parsing on the keys parsed into globalEnvMap, for files and LoadReader
+alike: commands, the adapters when pull is set, file refs, base64, expanding, derived keys, sanitizing,
+warnings and the schema
+*/
+func (l *Loader) resolve(cfg settings, globalEnvMap *Map, pull bool) (*Map, error) {
+	var err error
+
+	// commands only run for values from files, adapters return values as they are
+	if cfg.commands != nil {
+		err = substituteCommands(*cfg.commands, globalEnvMap)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	emap := NewMap()
+	if pull {
+		emap, err = l.pull(globalEnvMap)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.fileRefs {
+		for _, m := range []*Map{globalEnvMap, emap} {
+			err = readFileRefs(m)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if cfg.base64 {
+		for _, m := range []*Map{globalEnvMap, emap} {
+			err = decodeBase64(m)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if cfg.expands() {
+		err = expandMap(globalEnvMap, emap, func(key string) fileSettings {
+			return cfg.file(globalEnvMap.Source(key))
+		}, l.outer)
+		if err != nil {
+			return nil, err
+		}
+	}
+	globalEnvMap.SetMap(emap)
+
+	err = l.derive(globalEnvMap)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.sanitize {
+		for _, change := range Sanitize(globalEnvMap) {
+			cfg.logf("sanitized %s", change)
+		}
+	}
+
+	if cfg.warnings {
+		for _, warning := range Suspicious(globalEnvMap) {
+			cfg.logf("%s", warning)
+		}
+	}
+
+	if cfg.schema != nil {
+		err = checkSchema(cfg, globalEnvMap)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return globalEnvMap, nil
+}
+
+// checkSchema fills in the defaults of the schema, warns about deprecated keys and validates the map,
+// for the environment in the key the file metadata is checked against
+func checkSchema(cfg settings, emap *Map) error {
+	r := Layered(emap, Environ())
+
+	emap.SetMap(cfg.schema.defaults(r, cfg.envKey))
+
+	for _, warning := range cfg.schema.Deprecations(r) {
+		cfg.logf("%s", warning)
+	}
+
+	return cfg.schema.validate(r, cfg.envKey)
+}
+
+// LoadedFiles returns the files the last load read in the order they were read, which includes
+// `.local` shadow files (see WithLocalFiles) but not files that were skipped because they do not exist
+func (l *Loader) LoadedFiles() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]string(nil), l.files...)
+}
+
+// Load reads the files, runs the adapters and sets everything to the env, except the env vars that
+// are already set by something else than this Loader, see the package level Load
+func (l *Loader) Load(filenames ...string) error {
+	emap, err := l.Read(filenames...)
+	if err != nil {
+		return err
+	}
+
+	// set env map to env
+	return l.apply(l.unset(emap))
+}
+
+// Overload is Load but sets every key to the env, overriding the env vars that are already set
+func (l *Loader) Overload(filenames ...string) error {
+	emap, err := l.Read(filenames...)
+	if err != nil {
+		return err
+	}
+
+	return l.apply(emap)
+}
+
+/*
+LoadReader loads env content from r like Load loads a file, for content that is not in a file like an
+HTTP response body or a decrypted buffer. Only the content is loaded, the files and adapters are not,
+and the keys get "reader" as their source. Everything else applies to it like it does to a file: hooks,
+commands, file refs, base64, expanding, derived keys, sanitizing, warnings and the schema
+*/
+func (l *Loader) LoadReader(r io.Reader) error {
+	cfg := l.config()
+	emap := NewMap()
+
+	meta, err := cfg.parser.parse(emap, r, "reader")
+	if err != nil {
+		return err
+	}
+	err = checkMetadata(cfg, "reader", meta)
+	if err != nil {
+		return err
+	}
+
+	err = l.runHooks(emap, "reader")
+	if err != nil {
+		return err
+	}
+
+	emap, err = l.resolve(cfg, emap, false)
+	if err != nil {
+		return err
+	}
+
+	return l.apply(l.unset(emap))
+}
+
+// LoadSecure loads the files and adapters like Load, but puts everything in the store instead of the env
+func (l *Loader) LoadSecure(store *SecureStore, filenames ...string) error {
+	emap, err := l.Read(filenames...)
+	if err != nil {
+		return err
+	}
+
+	return store.SetMap(emap)
+}
+
+// LoadOnly loads the files and adapters like Load, but only sets the given keys to the env
+func (l *Loader) LoadOnly(keys ...string) error {
+	return l.loadFiltered(func(key string) bool {
+		return hasString(keys, key)
+	})
+}
+
+// LoadMatching loads the files and adapters like Load, but only sets the keys matching the glob pattern (e.g. `DB_*`)
+func (l *Loader) LoadMatching(pattern string) error {
+	// catch a bad pattern before matching, path.Match only reports it when it gets that far in a key
+	_, err := path.Match(pattern, "")
+	if err != nil {
+		return fmt.Errorf("bad pattern %q: %s", pattern, err)
+	}
+
+	return l.loadFiltered(func(key string) bool {
+		ok, _ := path.Match(pattern, key)
+		return ok
+	})
+}
+
+func (l *Loader) loadFiltered(keep func(key string) bool) error {
+	emap, err := l.Read()
+	if err != nil {
+		return err
+	}
+
+	return l.apply(l.unset(emap.filter(keep)))
+}
+
+// MustLoad calls Load and then errors if any of the required keys are missing
+func (l *Loader) MustLoad(filenames ...string) error {
+	emap, err := l.Read(filenames...)
+	if err != nil {
+		return err
+	}
+
+	err = l.apply(l.unset(emap))
+	if err != nil {
+		return err
+	}
+
+	return l.checkRequiredKeys(emap)
+}
+
+// LoadOnce calls Load only the first time it is called, see the package level LoadOnce
+func (l *Loader) LoadOnce(filenames ...string) error {
+	if len(filenames) == 0 {
+		filenames = l.config().filenames
+	}
+
+	l.mu.Lock()
+	o := l.once
+	l.mu.Unlock()
+
+	o.Do(func() {
+		o.filenames = filenames
+		o.err = l.Load(filenames...)
+	})
+
+	if !equalStrings(o.filenames, filenames) {
+		return fmt.Errorf("%w: loaded %s, asked for %s", ErrLoadedWithDifferentFiles, o.filenames, filenames)
+	}
+
+	return o.err
+}
+
+// LoadSecrets runs only the adapters and sets what they return to the env
+func (l *Loader) LoadSecrets() error {
+	emap, err := l.pull(NewMap())
+	if err != nil {
+		return err
+	}
+
+	// set env map to env
+	return l.apply(emap)
+}
+
+// MustLoadSecrets calls LoadSecrets and then errors if any of the required keys are missing
+func (l *Loader) MustLoadSecrets() error {
+	emap, err := l.pull(NewMap())
+	if err != nil {
+		return err
+	}
+
+	err = l.apply(emap)
+	if err != nil {
+		return err
+	}
+
+	return l.checkRequiredKeys(emap)
+}
+
+// unset returns the keys of emap that are not in the env yet, or that this Loader set there itself
+// so loading again after a file changed still updates them
+func (l *Loader) unset(emap *Map) *Map {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return emap.filter(func(key string) bool {
+		_, ok := os.LookupEnv(key)
+		return !ok || l.owns(key)
+	})
+}
+
+// owns reports if the value of the key in the env is the one this Loader set, l.mu has to be held
+func (l *Loader) owns(key string) bool {
+	if l.loaded == nil {
+		return false
+	}
+
+	val, ok := os.LookupEnv(key)
+	loaded, set := l.loaded.Lookup(key)
+
+	return ok && set && loaded == val
+}
+
+// outer returns the value of the key from before this Loader set it, what a key referencing itself
+// like PATH=${PATH}:/extra expands so loading again does not append /extra twice
+func (l *Loader) outer(key string) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.owns(key) {
+		return os.LookupEnv(key)
+	}
+
+	val, ok := l.prior[key]
+	return val, ok
+}
+
+// apply sets the map to the env and remembers the keys for LoadedKeys
+func (l *Loader) apply(emap *Map) error {
+	// what the keys this Loader does not own are set to now, only remembered once they are replaced
+	var replaced []string
+	prior := make(map[string]string)
+
+	l.mu.Lock()
+	for _, key := range emap.Ordered() {
+		if l.owns(key) {
+			continue
+		}
+
+		replaced = append(replaced, key)
+		if val, ok := os.LookupEnv(key); ok {
+			prior[key] = val
+		}
+	}
+	l.mu.Unlock()
+
+	err := setEnvMap(l.config(), emap)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.prior == nil {
+		l.prior = make(map[string]string)
+	}
+	for _, key := range replaced {
+		delete(l.prior, key)
+		if val, ok := prior[key]; ok {
+			l.prior[key] = val
+		}
+	}
+
+	if l.loaded == nil {
+		l.loaded = NewMap()
+	}
+	l.loaded.SetMap(emap)
+
+	return nil
+}
+
+/*
+LoadedKeys returns the sorted keys the Loader has set to the env. Container entrypoints can use it
+to unset secrets once they have been handed to the app
+
+	for _, key := range loader.LoadedKeys() {
+		os.Unsetenv(key)
+	}
+*/
+func (l *Loader) LoadedKeys() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.loaded == nil {
+		return nil
+	}
+
+	return l.loaded.Keys()
+}
+
+// pull runs the adapters in the order they were applied and merges what they return, files is what
+// the files loaded for the adapters that read from it
+func (l *Loader) pull(files *Map) (*Map, error) {
+	cfg := l.config()
+
+	l.mu.Lock()
+	adapters := append([]*Adapter(nil), l.adapters...)
+	l.mu.Unlock()
+
+	globalEnvMap := NewMap()
+	loaded := Layered(globalEnvMap, files, Environ())
+
+	// run pull secrets from adapters
+	for i, adapter := range adapters {
+		name := adapter.Name
+		if name == "" {
+			name = fmt.Sprintf("adapter %d", i+1)
+		}
+
+		if cfg.offline && adapter.Network {
+			if adapter.Required {
+				return nil, &AdapterError{Adapter: name, Err: ErrOffline}
+			}
+
+			cfg.logf("skipped %s, it needs the network", name)
+			continue
+		}
+
+		// pulling secrets
+		start := time.Now()
+		emap, err := adapter.pull(loaded)
+		l.recordPull(name, start, err)
+		if err != nil {
+			return nil, &AdapterError{Adapter: name, Err: err}
+		}
+
+		for _, key := range emap.Ordered() {
+			if cfg.parser.validKeys && !isName(key) {
+				return nil, &KeyError{Key: key, Source: name}
+			}
+			emap.SetSource(key, name)
+		}
+
+		err = l.runHooks(emap, name)
+		if err != nil {
+			return nil, err
+		}
+
+		// set adapters EnvMap to global EnvMap
+		globalEnvMap.SetMap(emap)
+	}
+
+	return globalEnvMap, nil
+}
+
+// adapterPull is how the last pull of an adapter went, for Handler
+type adapterPull struct {
+	Name     string    `json:"name"`
+	Time     time.Time `json:"time"`
+	Duration string    `json:"duration"`
+
+	// Error is the error of the last pull, LastSuccess is when it last worked
+	Error       string    `json:"error,omitempty"`
+	LastSuccess time.Time `json:"last_success"`
+}
+
+func (l *Loader) recordPull(name string, start time.Time, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.pulls == nil {
+		l.pulls = make(map[string]*adapterPull)
+	}
+
+	pull, ok := l.pulls[name]
+	if !ok {
+		pull = &adapterPull{Name: name}
+		l.pulls[name] = pull
+	}
+
+	pull.Time = start
+	pull.Duration = time.Since(start).String()
+	pull.Error = ""
+	if err != nil {
+		pull.Error = err.Error()
+	} else {
+		pull.LastSuccess = start
+	}
+}
+
+// checkMetadata makes sure a file stamped for an environment is only loaded in that environment
+func checkMetadata(cfg settings, filename string, meta Metadata) error {
+	fileEnv := meta["environment"]
+	if fileEnv == "" {
+		return nil
+	}
+
+	current, ok := os.LookupEnv(cfg.envKey)
+	if ok && current != fileEnv {
+		return &EnvironmentError{Filename: filename, File: fileEnv, Current: current}
+	}
+
+	return nil
+}
+
+/*
+checkRequiredKeys checks the required keys, and the keys in emap or the schema that have a required tag,
+are in the env and not empty. An empty value passes if it is empty on purpose in emap or WithAllowEmpty
+is set. Tags in the schema are what catch a tagged key that no file sets at all
+*/
+func (l *Loader) checkRequiredKeys(emap *Map) error {
+	cfg := l.config()
+
+	l.mu.Lock()
+	requiredKeys := append([]string(nil), l.requiredKeys...)
+	for _, tag := range l.requiredTags {
+		requiredKeys = append(requiredKeys, emap.Tagged(tag)...)
+		if cfg.schema != nil {
+			requiredKeys = append(requiredKeys, cfg.schema.Tagged(tag)...)
+		}
+	}
+	l.mu.Unlock()
+
+	var missingKeys []string
+	checked := make(map[string]bool)
+
+	for _, key := range requiredKeys {
+		if checked[key] {
+			continue
+		}
+		checked[key] = true
+
+		val, ok := os.LookupEnv(key)
+
+		if !ok || val == "" && !cfg.allowEmpty && !emap.IsEmpty(key) {
+			missingKeys = append(missingKeys, key)
+		}
+	}
+
+	if len(missingKeys) != 0 {
+		return fmt.Errorf("Required keys missing or empty: %s", missingKeys)
+	}
+
+	return nil
+}
+
+/*
+expandFilename expands the ${VAR} references in a filename from the env, like .env.${APP_ENV}, with
+the same syntax as values so ${APP_ENV:-development} has a default. ok is false when it references
+an env var that is not set and has no default
+*/
+func expandFilename(filename string) (string, bool, error) {
+	if !strings.Contains(filename, "${") {
+		return filename, true, nil
+	}
+
+	x := &expander{
+		raw: func(key string) (string, bool, bool) {
+			val, ok := os.LookupEnv(key)
+			return val, ok, false
+		},
+		done:   make(map[string]string),
+		stack:  []string{filename},
+		strict: func(string) bool { return true },
+	}
+
+	expanded, err := x.expand(filename)
+	if err != nil {
+		return "", false, &FileError{Filename: filename, Err: err}
+	}
+	if len(x.undefined) != 0 {
+		return filename, false, nil
+	}
+
+	return expanded, true, nil
+}
+
+/*
+parseFile parses the file into emap and reports if it did. Files that do not exist are skipped since
+env files are usually optional, anything else that is wrong with a filename is returned as a *FileError
+*/
+func parseFile(cfg settings, emap *Map, filename string) (bool, error) {
+	err := checkFilename(filename)
+	if err != nil {
+		return false, err
+	}
+
+	f, err := os.Stat(filename)
+	if os.IsNotExist(err) {
+		cfg.logf("could not load %s: %s", filename, err)
+		return false, nil
+	}
+	if err != nil {
+		return false, &FileError{Filename: filename, Err: err}
+	}
+
+	if f.IsDir() {
+		return false, &FileError{
+			Filename:   filename,
+			Err:        ErrIsDirectory,
+			Suggestion: fmt.Sprintf("pass the files in it instead, e.g. filepath.Glob(%q)", filepath.Join(filename, "*.env")),
+		}
+	}
+
+	// writers lock the file a link points to, so a load through the link has to take the same lock
+	lockPath, err := editPath(filename)
+	if err != nil {
+		return false, &FileError{Filename: filename, Err: err}
+	}
+
+	unlock, err := lockFile(lockPath, false)
+	if err != nil {
+		return false, &FileError{Filename: filename, Err: err}
+	}
+	defer unlock()
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return false, &FileError{Filename: filename, Err: err}
+	}
+	defer file.Close()
+
+	if format := fileFormat(filename); format != nil {
+		return true, parseFormat(cfg, emap, file, filename, format)
+	}
+
+	meta, err := cfg.parser.parse(emap, file, filename)
+	if err != nil {
+		return false, &FileError{Filename: filename, Err: err}
+	}
+
+	return true, checkMetadata(cfg, filename, meta)
+}
+
+// exists reports if there is a file at the path
+func exists(path string) bool {
+	f, err := os.Stat(path)
+
+	return err == nil && !f.IsDir()
+}
+
+// checkFilename catches filenames that are obviously a mistake before they hit the filesystem
+func checkFilename(filename string) error {
+	if strings.TrimSpace(filename) == "" {
+		return &FileError{
+			Filename:   filename,
+			Err:        ErrEmptyFilename,
+			Suggestion: "call Load with no arguments to load .env",
+		}
+	}
+
+	if u, err := url.Parse(filename); err == nil && len(u.Scheme) > 1 && u.Host != "" {
+		return &FileError{
+			Filename:   filename,
+			Err:        ErrURLFilename,
+			Suggestion: "use an Adapter to pull env vars from a remote source",
+		}
+	}
+
+	return nil
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,184 @@
+package env
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadReader(t *testing.T) {
+	secret := filepath.Join(filepath.Dir(writeEnvFile(t, "")), "secret")
+	err := ioutil.WriteFile(secret, []byte("s3cret\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := "READER_CERT=base64:aGVsbG8=\nREADER_KEY=file://" + secret + "\nREADER_HOST=db\nREADER_URL=${READER_HOST}:5432\n"
+	keys := []string{"READER_CERT", "READER_KEY", "READER_HOST", "READER_URL", "READER_DERIVED"}
+
+	// the reader goes through the same steps a file does, so both load the same env
+	loaders := map[string]func(l *Loader) error{
+		"file":   func(l *Loader) error { return l.Load(writeEnvFile(t, content)) },
+		"reader": func(l *Loader) error { return l.LoadReader(strings.NewReader(content)) },
+	}
+
+	for name, load := range loaders {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				for _, key := range keys {
+					os.Unsetenv(key)
+				}
+			}()
+
+			l := NewLoader(WithBase64(), WithFileRefs(), WithExpand())
+			l.Derive("READER_DERIVED", func(m *Map) string {
+				return m.Map["READER_URL"] + "/app"
+			})
+
+			err := load(l)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := map[string]string{
+				"READER_CERT":    "hello",
+				"READER_KEY":     "s3cret",
+				"READER_HOST":    "db",
+				"READER_URL":     "db:5432",
+				"READER_DERIVED": "db:5432/app",
+			}
+			for _, key := range keys {
+				if got := os.Getenv(key); got != want[key] {
+					t.Errorf("%s = %q, want %q", key, got, want[key])
+				}
+			}
+		})
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	tests := []struct {
+		name string
+		load func(l *Loader, filenames ...string) error
+		want string
+	}{
+		{"Load keeps the env var", (*Loader).Load, "shell"},
+		{"Overload overrides it", (*Loader).Overload, "file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("PRECEDENCE_SET", "shell")
+			defer os.Unsetenv("PRECEDENCE_SET")
+			defer os.Unsetenv("PRECEDENCE_NEW")
+
+			l := NewLoader()
+			err := tt.load(l, writeEnvFile(t, "PRECEDENCE_SET=file\nPRECEDENCE_NEW=1\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := os.Getenv("PRECEDENCE_SET"); got != tt.want {
+				t.Errorf("PRECEDENCE_SET = %q, want %q", got, tt.want)
+			}
+			if got := os.Getenv("PRECEDENCE_NEW"); got != "1" {
+				t.Errorf("PRECEDENCE_NEW = %q, want 1", got)
+			}
+
+			// what the loader set itself is not in the way of the next load
+			err = tt.load(l, writeEnvFile(t, "PRECEDENCE_SET=file\nPRECEDENCE_NEW=2\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := os.Getenv("PRECEDENCE_NEW"); got != "2" {
+				t.Errorf("after the reload PRECEDENCE_NEW = %q, want 2", got)
+			}
+		})
+	}
+}
+
+func TestRequiredTagged(t *testing.T) {
+	schema, err := ParseSchema([]byte(`{"version": 1, "keys": {"TAGGED_DB_PASS": {"tags": ["database"]}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := writeEnvFile(t, "# @tag:database\nTAGGED_DB_HOST=localhost\nTAGGED_OTHER=1\n")
+	defer func() {
+		for _, key := range []string{"TAGGED_DB_HOST", "TAGGED_OTHER", "TAGGED_DB_PASS"} {
+			os.Unsetenv(key)
+		}
+	}()
+
+	// TAGGED_DB_PASS is in no file, only its tag in the schema makes it required
+	l := NewLoader(WithSchema(schema))
+	l.RequiredTagged("database")
+
+	err = l.MustLoad(path)
+	if err == nil || !strings.Contains(err.Error(), "TAGGED_DB_PASS") {
+		t.Fatalf("got error %v, want TAGGED_DB_PASS to be missing", err)
+	}
+	if strings.Contains(err.Error(), "TAGGED_DB_HOST") || strings.Contains(err.Error(), "TAGGED_OTHER") {
+		t.Errorf("got error %v, only TAGGED_DB_PASS is missing", err)
+	}
+
+	os.Setenv("TAGGED_DB_PASS", "hunter2")
+	err = l.MustLoad(path)
+	if err != nil {
+		t.Errorf("got error %v once every tagged key is set", err)
+	}
+}
+
+func TestAdaptersReadLoaded(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/jwt/login":
+			w.Write([]byte(`{"auth": {"client_token": "vault-token"}}`))
+		case "/task":
+			w.Write([]byte(`{"Cluster": "prod"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	creds := filepath.Dir(writeEnvFile(t, ""))
+	err := ioutil.WriteFile(filepath.Join(creds, "db-password"), []byte("hunter2\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the settings are only in the files, not in the env
+	files := NewMap()
+	files.Set("VAULT_ADDR", srv.URL)
+	files.Set("ECS_CONTAINER_METADATA_URI_V4", srv.URL)
+	files.Set("CREDENTIALS_DIRECTORY", creds)
+
+	tests := []struct {
+		adapter  *Adapter
+		key, val string
+	}{
+		{VaultJWT(VaultJWTOptions{Role: "app", Token: func() (string, error) { return "jwt", nil }}), "VAULT_TOKEN", "vault-token"},
+		{ECSMetadata(MetadataOptions{}), "ECS_CLUSTER", "prod"},
+		{SystemdCredentials("db-password"), "DB_PASSWORD", "hunter2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.adapter.Name, func(t *testing.T) {
+			l := NewLoader()
+			l.ApplyAdapter(tt.adapter)
+
+			emap, err := l.pull(files)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := emap.Map[tt.key]; got != tt.val {
+				t.Errorf("%s = %q, want %q", tt.key, got, tt.val)
+			}
+		})
+	}
+}
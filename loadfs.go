@@ -0,0 +1,92 @@
+//go:build go1.16
+// +build go1.16
+
+package env
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// LoadFS loads the files from fsys, and then the files and adapters on top of them, see Loader.LoadFS
+func LoadFS(fsys fs.FS, names ...string) error {
+	return getDefaultLoader().LoadFS(fsys, names...)
+}
+
+/*
+LoadFS loads the files from fsys, `.env` if no names are given, and then the files and adapters of the
+Loader on top of them like Load. A binary can embed a default `.env` with go:embed and the real files on
+disk and the adapters still override it
+
+	//go:embed .env
+	var defaults embed.FS
+
+	err := env.LoadFS(defaults)
+
+Files that are not in fsys are skipped like files that are not on disk
+*/
+func (l *Loader) LoadFS(fsys fs.FS, names ...string) error {
+	cfg := l.config()
+
+	if len(names) == 0 {
+		names = envFileNames
+	}
+
+	emap := NewMap()
+	var read []string
+
+	for _, name := range names {
+		ok, err := parseFS(cfg, emap, fsys, name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		read = append(read, name)
+
+		err = l.runHooks(emap, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	emap, err := l.read(emap, read, nil)
+	if err != nil {
+		return err
+	}
+
+	return l.apply(l.unset(emap))
+}
+
+// parseFS parses the file in fsys into emap and reports if it did, like parseFile does for files on disk
+func parseFS(cfg settings, emap *Map, fsys fs.FS, name string) (bool, error) {
+	f, err := fsys.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		cfg.logf("could not load %s: %s", name, err)
+		return false, nil
+	}
+	if err != nil {
+		return false, &FileError{Filename: name, Err: err}
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return false, &FileError{Filename: name, Err: err}
+	}
+	if info.IsDir() {
+		return false, &FileError{Filename: name, Err: ErrIsDirectory}
+	}
+
+	if format := fileFormat(name); format != nil {
+		return true, parseFormat(cfg, emap, f, name, format)
+	}
+
+	meta, err := cfg.parser.parse(emap, f, name)
+	if err != nil {
+		return false, &FileError{Filename: name, Err: err}
+	}
+
+	return true, checkMetadata(cfg, name, meta)
+}
@@ -0,0 +1,167 @@
+package env
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+GetLanguage returns the env var as a BCP 47 language tag like en, pt-BR or zh-Hant-TW, in its
+canonical case. POSIX locales like en_US.UTF-8 are taken as en-US, since that is how $LANG is written.
+The tag is checked to be well formed, not that its subtags are registered.
+
+It returns a string and not a language.Tag since env only uses the standard library, the envlocale
+package has the same getter returning a language.Tag
+*/
+func GetLanguage(key string) (string, error) {
+	val, err := lookupSet(key)
+	if err != nil {
+		return "", err
+	}
+
+	tag, err := canonicalLanguage(val)
+	if err != nil {
+		return "", fmt.Errorf("%s is not a language tag: %s", key, err)
+	}
+
+	return tag, nil
+}
+
+// canonicalLanguage checks the tag is well formed and gives it the canonical case
+func canonicalLanguage(tag string) (string, error) {
+	tag = strings.TrimSpace(tag)
+
+	// the charset and modifier of a POSIX locale, like .UTF-8 or @euro
+	locale := tag
+	if i := strings.IndexAny(locale, ".@"); i != -1 {
+		locale = locale[:i]
+	}
+
+	subtags := strings.Split(strings.Replace(locale, "_", "-", -1), "-")
+	for i, s := range subtags {
+		if s == "" || len(s) > 8 || !isAlnum(s) {
+			return "", fmt.Errorf("%q is not a valid subtag of %q", s, tag)
+		}
+		subtags[i] = strings.ToLower(s)
+	}
+
+	// private use tags like x-klingon
+	if subtags[0] == "x" {
+		if len(subtags) == 1 {
+			return "", fmt.Errorf("%q has no private use subtags", tag)
+		}
+		return strings.Join(subtags, "-"), nil
+	}
+
+	if lang := subtags[0]; len(lang) < 2 || len(lang) == 4 || !isAlpha(lang) {
+		return "", fmt.Errorf("%q does not start with a language", tag)
+	}
+
+	i := 1
+
+	// up to 3 extended language subtags, like zh-yue
+	for n := 0; n < 3 && i < len(subtags) && len(subtags[0]) <= 3 && len(subtags[i]) == 3 && isAlpha(subtags[i]); n++ {
+		i++
+	}
+
+	// script, like Hant
+	if i < len(subtags) && len(subtags[i]) == 4 && isAlpha(subtags[i]) {
+		subtags[i] = strings.ToUpper(subtags[i][:1]) + subtags[i][1:]
+		i++
+	}
+
+	// region, like BR or 419
+	if i < len(subtags) && (len(subtags[i]) == 2 && isAlpha(subtags[i]) || len(subtags[i]) == 3 && isDigits(subtags[i])) {
+		subtags[i] = strings.ToUpper(subtags[i])
+		i++
+	}
+
+	// variants, like 1996 or valencia
+	for i < len(subtags) && (len(subtags[i]) >= 5 || len(subtags[i]) == 4 && isDigits(subtags[i][:1])) {
+		i++
+	}
+
+	// extensions like u-ca-buddhist, then private use
+	for i < len(subtags) && len(subtags[i]) == 1 {
+		singleton := subtags[i]
+		i++
+
+		n := 0
+		for i < len(subtags) && (len(subtags[i]) >= 2 || singleton == "x") {
+			i++
+			n++
+		}
+		if n == 0 {
+			return "", fmt.Errorf("extension %s of %q is empty", singleton, tag)
+		}
+		if singleton == "x" {
+			break
+		}
+	}
+
+	if i != len(subtags) {
+		return "", fmt.Errorf("unexpected subtag %q in %q", subtags[i], tag)
+	}
+
+	return strings.Join(subtags, "-"), nil
+}
+
+// GetCurrency returns the env var as an upper case ISO 4217 currency code, like USD or EUR, envlocale.GetCurrency returns a currency.Unit
+func GetCurrency(key string) (string, error) {
+	val, err := lookupSet(key)
+	if err != nil {
+		return "", err
+	}
+
+	code := strings.ToUpper(strings.TrimSpace(val))
+	if !currencies[code] {
+		return "", fmt.Errorf("%s is not an ISO 4217 currency code: %q", key, val)
+	}
+
+	return code, nil
+}
+
+// currencies are the active ISO 4217 currency codes, and the funds and metals codes
+var currencies = map[string]bool{}
+
+func init() {
+	codes := `AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BHD BIF BMD BND BOB BOV BRL BSD BTN
+	BWP BYN BZD CAD CDF CHE CHF CHW CLF CLP CNY COP COU CRC CUC CUP CVE CZK DJF DKK DOP DZD EGP ERN ETB
+	EUR FJD FKP GBP GEL GHS GIP GMD GNF GTQ GYD HKD HNL HTG HUF IDR ILS INR IQD IRR ISK JMD JOD JPY KES
+	KGS KHR KMF KPW KRW KWD KYD KZT LAK LBP LKR LRD LSL LYD MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK
+	MXN MXV MYR MZN NAD NGN NIO NOK NPR NZD OMR PAB PEN PGK PHP PKR PLN PYG QAR RON RSD RUB RWF SAR SBD
+	SCR SDG SEK SGD SHP SLE SLL SOS SRD SSP STN SVC SYP SZL THB TJS TMT TND TOP TRY TTD TWD TZS UAH UGX
+	USD USN UYI UYU UYW UZS VED VES VND VUV WST XAF XAG XAU XBA XBB XBC XBD XCD XCG XDR XOF XPD XPF XPT
+	XSU XTS XUA XXX YER ZAR ZMW ZWG ZWL`
+
+	for _, code := range strings.Fields(codes) {
+		currencies[code] = true
+	}
+}
+
+func isAlpha(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i] | 0x20; c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlnum(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isAlpha(s[i:i+1]) && !isDigits(s[i:i+1]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,37 @@
+package env
+
+import "os"
+
+/*
+lockFile takes an advisory lock on the sidecar `<path>.lock` file, exclusive for writers and shared for readers,
+so the CLI, the app and anything else following the same convention never read an env file while
+another process is in the middle of changing it.
+
+The env file itself can not be locked since writes rename a new file over it. Writers create the
+lock file, readers only lock it if it exists so loading never leaves files behind
+*/
+func lockFile(path string, exclusive bool) (unlock func(), err error) {
+	flag := os.O_RDONLY
+	if exclusive {
+		flag = os.O_RDWR | os.O_CREATE
+	}
+
+	f, err := os.OpenFile(path+".lock", flag, 0644)
+	if os.IsNotExist(err) && !exclusive {
+		return func() {}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = lockFD(f, exclusive)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return func() {
+		unlockFD(f)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly && !windows
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly,!windows
+
+package env
+
+import "os"
+
+// there is no portable advisory lock on the other platforms, writes from this process are still serialized by fileLocks
+
+func lockFD(f *os.File, exclusive bool) error {
+	return nil
+}
+
+func unlockFD(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,134 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly || windows
+// +build linux darwin freebsd netbsd openbsd dragonfly windows
+
+package env
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLockFile(t *testing.T) {
+	path := writeEnvFile(t, "A=1\n")
+
+	// a reader does not create the lock file, so it can lock a file nobody writes to
+	unlock, err := lockFile(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock()
+
+	unlock, err = lockFile(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a reader waits for the writer to be done
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		unlock, err := lockFile(path, false)
+		if err == nil {
+			unlock()
+		}
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("a shared lock was taken while the exclusive lock was held")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	<-done
+}
+
+func TestLoadThroughSymlinkWaitsForWriter(t *testing.T) {
+	target := writeEnvFile(t, "A=1\n")
+	link := filepath.Join(filepath.Dir(target), "link.env")
+	if err := os.Symlink(filepath.Base(target), link); err != nil {
+		t.Skipf("can not create symlinks: %s", err)
+	}
+
+	// an edit through either path locks the file the link points to
+	abs, err := editPath(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := lockFile(abs, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		NewLoader().Read(link)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("the link was read while the file it points to was being written")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	<-done
+}
+
+func TestConcurrentEdits(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(path, key string) error
+	}{
+		{"UpsertInFile", func(path, key string) error {
+			return UpsertInFile(path, key, "1")
+		}},
+		{"AppendToFile", func(path, key string) error {
+			return AppendToFile(path, key, "1")
+		}},
+		{"EditDocument", func(path, key string) error {
+			return EditDocument(path, func(doc *Document) error {
+				return doc.Set(key, "1")
+			})
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeEnvFile(t, "# keys\n")
+
+			// every edit reads the file and writes it back, one that is lost drops a key
+			var wg sync.WaitGroup
+			errs := make(chan error, 20)
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func(key string) {
+					defer wg.Done()
+					errs <- tt.edit(path, key)
+				}(fmt.Sprintf("KEY_%d", i))
+			}
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			doc, err := ReadDocument(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if keys := doc.Keys(); len(keys) != 20 {
+				t.Errorf("the file has %d keys after 20 edits: %s", len(keys), keys)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+//go:build windows
+// +build windows
+
+package env
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+const lockfileExclusiveLock = 0x2
+
+// lockFD locks the whole file, the same as flock does on unix
+func lockFD(f *os.File, exclusive bool) error {
+	var flags uintptr
+	if exclusive {
+		flags = lockfileExclusiveLock
+	}
+
+	ol := new(syscall.Overlapped)
+	r, _, err := procLockFileEx.Call(f.Fd(), flags, 0, 0xffffffff, 0xffffffff, uintptr(unsafe.Pointer(ol)))
+	if r == 0 {
+		return err
+	}
+
+	return nil
+}
+
+func unlockFD(f *os.File) error {
+	ol := new(syscall.Overlapped)
+	r, _, err := procUnlockFileEx.Call(f.Fd(), 0, 0xffffffff, 0xffffffff, uintptr(unsafe.Pointer(ol)))
+	if r == 0 {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package env
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+LogLevel is a log level normalized to one of trace, debug, info, warn, error or fatal. The names
+parse as they are with zapcore.ParseLevel (other than trace) and logrus.ParseLevel, and Slog
+converts it to a slog.Level
+*/
+type LogLevel string
+
+// The levels GetLogLevel returns
+const (
+	LevelTrace LogLevel = "trace"
+	LevelDebug LogLevel = "debug"
+	LevelInfo  LogLevel = "info"
+	LevelWarn  LogLevel = "warn"
+	LevelError LogLevel = "error"
+	LevelFatal LogLevel = "fatal"
+)
+
+// logLevels maps the names loggers use for their levels to the normalized level
+var logLevels = map[string]LogLevel{
+	"trace":    LevelTrace,
+	"debug":    LevelDebug,
+	"info":     LevelInfo,
+	"notice":   LevelInfo,
+	"warn":     LevelWarn,
+	"warning":  LevelWarn,
+	"error":    LevelError,
+	"err":      LevelError,
+	"fatal":    LevelFatal,
+	"critical": LevelFatal,
+	"crit":     LevelFatal,
+	"panic":    LevelFatal,
+	"dpanic":   LevelFatal,
+}
+
+// GetLogLevel returns the env var as a LogLevel, the level names are not case sensitive and warning, err, critical and panic are taken too
+func GetLogLevel(key string) (LogLevel, error) {
+	val, err := lookupSet(key)
+	if err != nil {
+		return "", err
+	}
+
+	level, ok := logLevels[strings.ToLower(strings.TrimSpace(val))]
+	if !ok {
+		return "", fmt.Errorf("%s is not a log level: %q", key, val)
+	}
+
+	return level, nil
+}
+
+func (l LogLevel) String() string {
+	return string(l)
+}
@@ -0,0 +1,24 @@
+//go:build go1.21
+// +build go1.21
+
+package env
+
+import "log/slog"
+
+// Slog returns the slog.Level of the level, slog has no trace or fatal so they are 4 below debug and 4 above error
+func (l LogLevel) Slog() slog.Level {
+	switch l {
+	case LevelTrace:
+		return slog.LevelDebug - 4
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelWarn:
+		return slog.LevelWarn
+	case LevelError:
+		return slog.LevelError
+	case LevelFatal:
+		return slog.LevelError + 4
+	}
+
+	return slog.LevelInfo
+}
@@ -0,0 +1,376 @@
+package env
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+LoadManifest creates a Loader from a manifest file, which describes the whole load in one committed
+file instead of Go code so the app and the envctl CLI (with --manifest) load the env the same way. The
+manifest can be YAML, TOML or JSON
+
+	files = [".env", ".env.${APP_ENV:-development}"]
+	local_files = true
+	expand = true
+	required = ["DATABASE_URL"]
+	schema = "env.schema.json"
+
+	# adapters run in this order after the files, the later ones win
+	adapters = ["aws-ssm", "aws-secrets-manager"]
+
+	[aws-ssm]
+	path = "/myapp/prod/"
+	recursive = true
+
+	[aws-secrets-manager]
+	prefix = "prod/"
+	required = true
+	rate_limit = "1m"
+
+Besides files, required, schema and adapters the top level takes the bools expand, strict, local_files,
+valid_keys, allow_empty, warnings, sanitize, base64, file_refs and offline, which turn on the option of
+the same name. Files and the schema are relative to the manifest. The built-in adapters are
+ec2-metadata, ecs-metadata and gce-metadata (tags, user_data), cloud-init (path), systemd-credentials
+(names), env-service (addr), aws-web-identity (role_arn, session_name, region, token_file), vault-jwt
+(addr, mount, role, token_file), aws-ssm (path, recursive, page_size, region) and aws-secrets-manager
+(names, prefix, page_size, region). Every adapter also takes required and rate_limit, see Adapter and
+RateLimit. Unknown settings are an error so a typo does not go unnoticed.
+
+opts are applied after the manifest, for what only makes sense in code like WithLogger
+*/
+func LoadManifest(path string, opts ...Option) (*Loader, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read manifest: %s", err)
+	}
+
+	format := fileFormat(path)
+	if format == nil {
+		return nil, fmt.Errorf("could not load manifest %s: it has to be a .yaml, .yml, .toml or .json file", path)
+	}
+
+	// nested keys are joined with __ so adapter settings can be told apart from the top level
+	fmap, err := format(data, "__")
+	if err != nil {
+		return nil, fmt.Errorf("could not load manifest %s: %s", path, err)
+	}
+
+	m := manifest{dir: filepath.Dir(path), values: fmap.Map, used: make(map[string]bool)}
+
+	l, err := m.loader(opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not load manifest %s: %s", path, err)
+	}
+
+	return l, nil
+}
+
+// manifestOptions are the bools of a manifest and the options they turn on
+var manifestOptions = map[string]Option{
+	"EXPAND":      WithExpand(),
+	"STRICT":      WithStrictExpand(),
+	"LOCAL_FILES": WithLocalFiles(),
+	"VALID_KEYS":  WithValidKeys(),
+	"ALLOW_EMPTY": WithAllowEmpty(),
+	"WARNINGS":    WithWarnings(),
+	"SANITIZE":    WithSanitize(),
+	"BASE64":      WithBase64(),
+	"FILE_REFS":   WithFileRefs(),
+	"OFFLINE":     WithOffline(),
+}
+
+// manifestAdapters create the built-in adapters from their settings in a manifest
+var manifestAdapters = map[string]func(s *manifestSection) *Adapter{
+	"ec2-metadata": func(s *manifestSection) *Adapter {
+		return EC2Metadata(MetadataOptions{Tags: s.bool("tags"), UserData: s.bool("user_data")})
+	},
+	"ecs-metadata": func(s *manifestSection) *Adapter {
+		return ECSMetadata(MetadataOptions{Tags: s.bool("tags"), UserData: s.bool("user_data")})
+	},
+	"gce-metadata": func(s *manifestSection) *Adapter {
+		return GCEMetadata(MetadataOptions{Tags: s.bool("tags"), UserData: s.bool("user_data")})
+	},
+	"cloud-init": func(s *manifestSection) *Adapter {
+		return CloudInitUserData(s.str("path"))
+	},
+	"systemd-credentials": func(s *manifestSection) *Adapter {
+		return SystemdCredentials(s.list("names")...)
+	},
+	"env-service": func(s *manifestSection) *Adapter {
+		return FromService(s.required("addr"))
+	},
+	"aws-web-identity": func(s *manifestSection) *Adapter {
+		opts := AWSWebIdentityOptions{RoleARN: s.str("role_arn"), SessionName: s.str("session_name"), Region: s.str("region")}
+		if file := s.str("token_file"); file != "" {
+			opts.Token = TokenFile(file)
+		}
+		return AWSWebIdentity(opts)
+	},
+	"vault-jwt": func(s *manifestSection) *Adapter {
+		return VaultJWT(VaultJWTOptions{
+			Addr:  s.str("addr"),
+			Mount: s.str("mount"),
+			Role:  s.str("role"),
+			Token: TokenFile(s.required("token_file")),
+		})
+	},
+	"aws-ssm": func(s *manifestSection) *Adapter {
+		return SSMParameters(SSMOptions{
+			AWSOptions: AWSOptions{Region: s.str("region")},
+			Path:       s.required("path"),
+			Recursive:  s.bool("recursive"),
+			PageSize:   s.int("page_size"),
+		})
+	},
+	"aws-secrets-manager": func(s *manifestSection) *Adapter {
+		return SecretsManagerSecrets(SecretsManagerOptions{
+			AWSOptions: AWSOptions{Region: s.str("region")},
+			Names:      s.list("names"),
+			Prefix:     s.str("prefix"),
+			PageSize:   s.int("page_size"),
+		})
+	},
+}
+
+// manifest is a parsed manifest, used remembers the keys that were read so the others can be reported
+type manifest struct {
+	dir    string
+	values map[string]string
+	used   map[string]bool
+}
+
+func (m *manifest) get(key string) (string, bool) {
+	m.used[key] = true
+	val, ok := m.values[key]
+
+	return val, ok
+}
+
+func (m *manifest) loader(extra []Option) (*Loader, error) {
+	var opts []Option
+
+	if val, ok := m.get("FILES"); ok {
+		items, err := manifestList("files", val)
+		if err != nil {
+			return nil, err
+		}
+
+		var files []string
+		for _, file := range items {
+			files = append(files, m.path(file))
+		}
+		opts = append(opts, WithFiles(files...))
+	}
+
+	var keys []string
+	for key := range manifestOptions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		val, ok := m.get(key)
+		if !ok {
+			continue
+		}
+
+		on, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a bool: %q", strings.ToLower(key), val)
+		}
+		if on {
+			opts = append(opts, manifestOptions[key])
+		}
+	}
+
+	if val, ok := m.get("SCHEMA"); ok {
+		schema, err := LoadSchema(m.path(val))
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithSchema(schema))
+	}
+
+	l := NewLoader(append(opts, extra...)...)
+
+	if val, ok := m.get("REQUIRED"); ok {
+		keys, err := manifestList("required", val)
+		if err != nil {
+			return nil, err
+		}
+		l.RequiredKeys(keys)
+	}
+
+	if val, ok := m.get("ADAPTERS"); ok {
+		names, err := manifestList("adapters", val)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, name := range names {
+			a, err := m.adapter(name)
+			if err != nil {
+				return nil, err
+			}
+			l.ApplyAdapter(a)
+		}
+	}
+
+	var unknown []string
+	for key := range m.values {
+		if !m.used[key] {
+			unknown = append(unknown, settingName(key))
+		}
+	}
+	if len(unknown) != 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown settings %s", strings.Join(unknown, ", "))
+	}
+
+	return l, nil
+}
+
+// settingName is how the setting of the key is written in the manifest, like aws-ssm.path for AWS_SSM__PATH
+func settingName(key string) string {
+	i := strings.Index(key, "__")
+	if i == -1 {
+		return strings.ToLower(key)
+	}
+
+	section := strings.ToLower(key[:i])
+	for name := range manifestAdapters {
+		if envName(name) == key[:i] {
+			section = name
+		}
+	}
+
+	return section + "." + strings.ToLower(strings.Replace(key[i+2:], "__", ".", -1))
+}
+
+// adapter creates the adapter from its settings
+func (m *manifest) adapter(name string) (*Adapter, error) {
+	create, ok := manifestAdapters[name]
+	if !ok {
+		var names []string
+		for name := range manifestAdapters {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		return nil, fmt.Errorf("unknown adapter %q, expected one of %s", name, strings.Join(names, ", "))
+	}
+
+	s := &manifestSection{m: m, name: name}
+	a := create(s)
+
+	a.Required = s.bool("required")
+	if every := s.str("rate_limit"); every != "" {
+		d, err := time.ParseDuration(every)
+		if err != nil {
+			return nil, fmt.Errorf("%s.rate_limit is not a duration: %q", name, every)
+		}
+		a = RateLimit(a, d)
+	}
+
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return a, nil
+}
+
+// manifestList splits a list of the manifest, the formats quote the items the way parseList reads them
+func manifestList(setting, val string) ([]string, error) {
+	items, err := parseList(val)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a list: %s", setting, err)
+	}
+
+	var list []string
+	for _, item := range items {
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list, nil
+}
+
+// path makes a path in the manifest relative to the directory of the manifest
+func (m *manifest) path(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+
+	return filepath.Join(m.dir, p)
+}
+
+// manifestSection reads the settings of an adapter, keeping the first error
+type manifestSection struct {
+	m    *manifest
+	name string
+	err  error
+}
+
+func (s *manifestSection) str(setting string) string {
+	val, _ := s.m.get(envName(s.name) + "__" + strings.ToUpper(setting))
+
+	return val
+}
+
+func (s *manifestSection) required(setting string) string {
+	val := s.str(setting)
+	if val == "" && s.err == nil {
+		s.err = fmt.Errorf("%s.%s is not set", s.name, setting)
+	}
+
+	return val
+}
+
+func (s *manifestSection) list(setting string) []string {
+	val := s.str(setting)
+	if val == "" {
+		return nil
+	}
+
+	items, err := manifestList(s.name+"."+setting, val)
+	if err != nil && s.err == nil {
+		s.err = err
+	}
+
+	return items
+}
+
+func (s *manifestSection) bool(setting string) bool {
+	val := s.str(setting)
+	if val == "" {
+		return false
+	}
+
+	b, err := strconv.ParseBool(val)
+	if err != nil && s.err == nil {
+		s.err = fmt.Errorf("%s.%s is not a bool: %q", s.name, setting, val)
+	}
+
+	return b
+}
+
+func (s *manifestSection) int(setting string) int {
+	val := s.str(setting)
+	if val == "" {
+		return 0
+	}
+
+	i, err := strconv.Atoi(val)
+	if err != nil && s.err == nil {
+		s.err = fmt.Errorf("%s.%s is not an int: %q", s.name, setting, val)
+	}
+
+	return i
+}
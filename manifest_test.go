@@ -0,0 +1,128 @@
+package env
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeManifest writes the manifest to a new temp dir and returns its path
+func writeManifest(t *testing.T, name, content string) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, name)
+	err = ioutil.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadManifest(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{"toml", "env.toml", `
+files = [".env", "/etc/app.env"]
+expand = true
+offline = true
+required = ["DATABASE_URL", "PORT"]
+adapters = ["aws-ssm"]
+
+[aws-ssm]
+path = "/app/prod/"
+required = true
+rate_limit = "1m"
+`},
+		{"yaml", "env.yaml", `
+files: [.env, /etc/app.env]
+expand: true
+offline: true
+required:
+  - DATABASE_URL
+  - PORT
+adapters: [aws-ssm]
+aws-ssm:
+  path: /app/prod/
+  required: true
+  rate_limit: 1m
+`},
+		{"json", "env.json", `{
+  "files": [".env", "/etc/app.env"],
+  "expand": true,
+  "offline": true,
+  "required": ["DATABASE_URL", "PORT"],
+  "adapters": ["aws-ssm"],
+  "aws-ssm": {"path": "/app/prod/", "required": true, "rate_limit": "1m"}
+}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeManifest(t, tt.file, tt.content)
+
+			l, err := LoadManifest(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg := l.config()
+
+			// files are relative to the manifest
+			files := []string{filepath.Join(filepath.Dir(path), ".env"), "/etc/app.env"}
+			if !reflect.DeepEqual(cfg.filenames, files) {
+				t.Errorf("files = %q, want %q", cfg.filenames, files)
+			}
+			if !cfg.expand || !cfg.offline || cfg.strict {
+				t.Errorf("expand = %v, offline = %v, strict = %v, want only expand and offline", cfg.expand, cfg.offline, cfg.strict)
+			}
+			if want := []string{"DATABASE_URL", "PORT"}; !reflect.DeepEqual(l.requiredKeys, want) {
+				t.Errorf("required = %q, want %q", l.requiredKeys, want)
+			}
+
+			if len(l.adapters) != 1 {
+				t.Fatalf("%d adapters, want 1", len(l.adapters))
+			}
+			if a := l.adapters[0]; a.Name != "aws-ssm" || !a.Required || !a.Network {
+				t.Errorf("adapter %+v, want a required aws-ssm adapter", a)
+			}
+		})
+	}
+}
+
+func TestLoadManifestErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		err     string
+	}{
+		{"format", "env.ini", "files = .env", "it has to be a .yaml, .yml, .toml or .json file"},
+		{"unknown setting", "env.toml", "expnad = true", "unknown settings expnad"},
+		{"unknown adapter setting", "env.toml", "adapters = [\"aws-ssm\"]\n[aws-ssm]\npath = \"/app/\"\nrecursve = true", "unknown settings aws-ssm.recursve"},
+		{"not a bool", "env.toml", `expand = "yes please"`, `expand is not a bool: "yes please"`},
+		{"unknown adapter", "env.toml", `adapters = ["vault"]`, `unknown adapter "vault"`},
+		{"missing adapter setting", "env.toml", `adapters = ["aws-ssm"]`, "aws-ssm.path is not set"},
+		{"bad rate limit", "env.toml", "adapters = [\"aws-ssm\"]\n[aws-ssm]\npath = \"/app/\"\nrate_limit = \"soon\"", `aws-ssm.rate_limit is not a duration: "soon"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadManifest(writeManifest(t, tt.file, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("LoadManifest error = %v, want it to contain %q", err, tt.err)
+			}
+		})
+	}
+}
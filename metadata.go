@@ -0,0 +1,305 @@
+package env
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MetadataOptions selects what the instance metadata adapters export besides the basics
+type MetadataOptions struct {
+	// Tags exports the tags of the instance, as <PREFIX>_TAG_<NAME>
+	Tags bool
+
+	// UserData exports the env in the user data of the instance, see ParseUserData
+	UserData bool
+
+	// Client is used for the requests, by default a client with a 2 second timeout
+	Client *http.Client
+
+	// Endpoint overrides where the metadata service is, mostly for testing
+	Endpoint string
+}
+
+// metadataKey is a key an instance metadata adapter sets and the path of its value, they are asked for in
+// order so the requests and the first error are the same every pull
+type metadataKey struct {
+	key  string
+	path string
+}
+
+func (o MetadataOptions) client() *http.Client {
+	if o.Client != nil {
+		return o.Client
+	}
+
+	return &http.Client{Timeout: 2 * time.Second}
+}
+
+func (o MetadataOptions) endpoint(fallback string) string {
+	if o.Endpoint != "" {
+		return strings.TrimSuffix(o.Endpoint, "/")
+	}
+
+	return fallback
+}
+
+/*
+EC2Metadata returns an adapter that exports metadata of the EC2 instance the app runs on, using IMDSv2:
+
+	AWS_REGION, EC2_INSTANCE_ID, EC2_INSTANCE_TYPE, EC2_AVAILABILITY_ZONE
+
+Tags need "instance metadata tags" to be turned on for the instance
+*/
+func EC2Metadata(opts MetadataOptions) *Adapter {
+	return &Adapter{
+		Name:    "ec2-metadata",
+		Network: true,
+		Pull: func() (*Map, error) {
+			base := opts.endpoint("http://169.254.169.254")
+			client := opts.client()
+
+			req, err := http.NewRequest(http.MethodPut, base+"/latest/api/token", nil)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "300")
+
+			token, err := doMetadata(client, req)
+			if err != nil {
+				return nil, fmt.Errorf("could not get an EC2 metadata token: %s", err)
+			}
+
+			get := func(path string) (string, error) {
+				req, err := http.NewRequest(http.MethodGet, base+"/latest/"+path, nil)
+				if err != nil {
+					return "", err
+				}
+				req.Header.Set("X-aws-ec2-metadata-token", token)
+
+				return doMetadata(client, req)
+			}
+
+			emap := NewMap()
+			for _, m := range []metadataKey{
+				{"AWS_REGION", "meta-data/placement/region"},
+				{"EC2_INSTANCE_ID", "meta-data/instance-id"},
+				{"EC2_INSTANCE_TYPE", "meta-data/instance-type"},
+				{"EC2_AVAILABILITY_ZONE", "meta-data/placement/availability-zone"},
+			} {
+				val, err := get(m.path)
+				if err != nil {
+					return nil, fmt.Errorf("could not get EC2 metadata %s: %s", m.path, err)
+				}
+
+				emap.Set(m.key, val)
+			}
+
+			if opts.Tags {
+				list, err := get("meta-data/tags/instance")
+				if err != nil {
+					return nil, fmt.Errorf("could not get EC2 instance tags, are instance metadata tags turned on? %s", err)
+				}
+
+				for _, tag := range strings.Fields(list) {
+					val, err := get("meta-data/tags/instance/" + tag)
+					if err != nil {
+						return nil, fmt.Errorf("could not get EC2 instance tag %s: %s", tag, err)
+					}
+
+					emap.Set("EC2_TAG_"+envName(tag), val)
+				}
+			}
+
+			if opts.UserData {
+				data, err := get("user-data")
+				if err != nil && err != errMetadataNotFound {
+					return nil, fmt.Errorf("could not get EC2 user data: %s", err)
+				}
+
+				userData, err := ParseUserData([]byte(data))
+				if err != nil {
+					return nil, err
+				}
+				emap.SetMap(userData)
+			}
+
+			return emap, nil
+		},
+	}
+}
+
+/*
+ECSMetadata returns an adapter that exports metadata of the ECS task the app runs in, using the
+task metadata endpoint in ECS_CONTAINER_METADATA_URI_V4, which is looked up in what was loaded before it:
+
+	ECS_CLUSTER, ECS_TASK_ARN, ECS_TASK_FAMILY, ECS_TASK_REVISION, ECS_AVAILABILITY_ZONE
+
+ECS has no user data, so only Tags is used from the options (the task tags, when the task role is allowed to list them)
+*/
+func ECSMetadata(opts MetadataOptions) *Adapter {
+	return loadedAdapter("ecs-metadata", true, func(loaded Reader) (*Map, error) {
+		base := opts.endpoint(getenv(loaded, "ECS_CONTAINER_METADATA_URI_V4"))
+		if base == "" {
+			return nil, fmt.Errorf("could not get ECS task metadata: ECS_CONTAINER_METADATA_URI_V4 is not set")
+		}
+
+		path := "/task"
+		if opts.Tags {
+			path = "/taskWithTags"
+		}
+
+		req, err := http.NewRequest(http.MethodGet, base+path, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := doMetadata(opts.client(), req)
+		if err != nil {
+			return nil, fmt.Errorf("could not get ECS task metadata: %s", err)
+		}
+
+		var task struct {
+			Cluster          string
+			TaskARN          string
+			Family           string
+			Revision         string
+			AvailabilityZone string
+			TaskTags         map[string]string
+		}
+		err = json.Unmarshal([]byte(body), &task)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse ECS task metadata: %s", err)
+		}
+
+		emap := NewMap()
+		emap.Set("ECS_CLUSTER", task.Cluster)
+		emap.Set("ECS_TASK_ARN", task.TaskARN)
+		emap.Set("ECS_TASK_FAMILY", task.Family)
+		emap.Set("ECS_TASK_REVISION", task.Revision)
+		emap.Set("ECS_AVAILABILITY_ZONE", task.AvailabilityZone)
+
+		var tags []string
+		for tag := range task.TaskTags {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+
+		for _, tag := range tags {
+			emap.Set("ECS_TAG_"+envName(tag), task.TaskTags[tag])
+		}
+
+		return emap, nil
+	})
+}
+
+/*
+GCEMetadata returns an adapter that exports metadata of the GCE instance the app runs on:
+
+	GCP_PROJECT, GCE_INSTANCE_ID, GCE_ZONE, GCE_REGION
+
+Tags exports the network tags as a comma separated GCE_TAGS, UserData uses the user-data attribute
+*/
+func GCEMetadata(opts MetadataOptions) *Adapter {
+	return &Adapter{
+		Name:    "gce-metadata",
+		Network: true,
+		Pull: func() (*Map, error) {
+			base := opts.endpoint("http://metadata.google.internal")
+			client := opts.client()
+
+			get := func(path string) (string, error) {
+				req, err := http.NewRequest(http.MethodGet, base+"/computeMetadata/v1/"+path, nil)
+				if err != nil {
+					return "", err
+				}
+				req.Header.Set("Metadata-Flavor", "Google")
+
+				return doMetadata(client, req)
+			}
+
+			emap := NewMap()
+			for _, m := range []metadataKey{
+				{"GCP_PROJECT", "project/project-id"},
+				{"GCE_INSTANCE_ID", "instance/id"},
+				{"GCE_ZONE", "instance/zone"},
+			} {
+				val, err := get(m.path)
+				if err != nil {
+					return nil, fmt.Errorf("could not get GCE metadata %s: %s", m.path, err)
+				}
+
+				emap.Set(m.key, val)
+			}
+
+			// the zone comes back as projects/<number>/zones/<zone>
+			zone := emap.Map["GCE_ZONE"]
+			zone = zone[strings.LastIndex(zone, "/")+1:]
+			emap.Set("GCE_ZONE", zone)
+			if i := strings.LastIndex(zone, "-"); i != -1 {
+				emap.Set("GCE_REGION", zone[:i])
+			}
+
+			if opts.Tags {
+				body, err := get("instance/tags")
+				if err != nil {
+					return nil, fmt.Errorf("could not get GCE instance tags: %s", err)
+				}
+
+				var tags []string
+				err = json.Unmarshal([]byte(body), &tags)
+				if err != nil {
+					return nil, fmt.Errorf("could not parse GCE instance tags: %s", err)
+				}
+
+				emap.Set("GCE_TAGS", strings.Join(tags, ","))
+			}
+
+			if opts.UserData {
+				data, err := get("instance/attributes/user-data")
+				if err != nil && err != errMetadataNotFound {
+					return nil, fmt.Errorf("could not get GCE user data: %s", err)
+				}
+
+				userData, err := ParseUserData([]byte(data))
+				if err != nil {
+					return nil, err
+				}
+				emap.SetMap(userData)
+			}
+
+			return emap, nil
+		},
+	}
+}
+
+// errMetadataNotFound is returned by doMetadata when the metadata service does not have the path
+var errMetadataNotFound = errors.New("not found")
+
+// doMetadata sends a request to a metadata service and returns the body
+func doMetadata(client *http.Client, req *http.Request) (string, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", errMetadataNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s %s: %s", req.Method, req.URL.Path, resp.Status)
+	}
+
+	return string(body), nil
+}
@@ -0,0 +1,38 @@
+package env
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestECSMetadataTagOrder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Cluster": "prod", "TaskTags": {"team": "a", "cost-center": "b", "env": "c", "app": "d"}}`))
+	}))
+	defer srv.Close()
+
+	loaded := NewMap()
+	loaded.Set("ECS_CONTAINER_METADATA_URI_V4", srv.URL)
+
+	// the tags come from a JSON object, they are still set in the same order every time
+	want := []string{"ECS_TAG_APP", "ECS_TAG_COST_CENTER", "ECS_TAG_ENV", "ECS_TAG_TEAM"}
+	for i := 0; i < 10; i++ {
+		emap, err := ECSMetadata(MetadataOptions{Tags: true}).PullFrom(loaded)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var tags []string
+		for _, key := range emap.Ordered() {
+			if strings.HasPrefix(key, "ECS_TAG_") {
+				tags = append(tags, key)
+			}
+		}
+		if !reflect.DeepEqual(tags, want) {
+			t.Fatalf("the tags are set in the order %q, want %q", tags, want)
+		}
+	}
+}
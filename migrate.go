@@ -0,0 +1,308 @@
+package env
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// MigrateOption changes what Migrate renames keys in
+type MigrateOption func(*migration)
+
+type migration struct {
+	files    []string
+	adapters []string
+	dryRun   bool
+}
+
+// MigrateFiles sets the env files Migrate renames keys in, by default those are the files the Loader loads
+func MigrateFiles(filenames ...string) MigrateOption {
+	return func(m *migration) {
+		m.files = filenames
+	}
+}
+
+// MigrateAdapters makes Migrate push the renamed keys with the named adapters as well, see Push
+func MigrateAdapters(names ...string) MigrateOption {
+	return func(m *migration) {
+		m.adapters = names
+	}
+}
+
+// MigrateDryRun makes Migrate only report what it would rename
+func MigrateDryRun() MigrateOption {
+	return func(m *migration) {
+		m.dryRun = true
+	}
+}
+
+// Rename is a key Migrate renamed, Where is "env" for the env of the process, a filename or the name of an adapter
+type Rename struct {
+	From  string
+	To    string
+	Where string
+}
+
+func (r Rename) String() string {
+	return fmt.Sprintf("%s -> %s in %s", r.From, r.To, r.Where)
+}
+
+// Migrate renames the keys in the env, the env files and the adapters, see Loader.Migrate
+func Migrate(renames map[string]string, opts ...MigrateOption) ([]Rename, error) {
+	return getDefaultLoader().Migrate(renames, opts...)
+}
+
+/*
+Migrate renames keys, from the keys of renames to their values, everywhere the Loader gets them:
+
+	the env of the process, for the keys that are set in it
+	the env files (the Loader's files, or those given with MigrateFiles) keeping their comments, see Document
+	the remote stores of the adapters given with MigrateAdapters, which push the new keys with their old values
+
+Adapters only push, so the old keys are left in remote stores for their owners to remove once nothing
+reads them. It returns every rename in the order it was done, with MigrateDryRun nothing is changed and
+the renames are what would be done. A new key that is already set in the env or a file, or two keys
+renamed to the same one, is an error checked before anything is changed. The files stay locked like
+with UpsertInFile from reading them to writing them.
+
+When a rename fails the env and the files are put back the way they were. Pushes can not be undone,
+so the renames returned with the error are the ones adapters already pushed
+*/
+func (l *Loader) Migrate(renames map[string]string, opts ...MigrateOption) ([]Rename, error) {
+	m := migration{files: l.config().filenames}
+	for _, opt := range opts {
+		opt(&m)
+	}
+
+	var froms []string
+	for from, to := range renames {
+		if !isName(to) {
+			return nil, fmt.Errorf("can not rename %s to %q: not a valid key", from, to)
+		}
+		froms = append(froms, from)
+	}
+	sort.Strings(froms)
+
+	targets := make(map[string]string)
+	for _, from := range froms {
+		to := renames[from]
+		if other, ok := targets[to]; ok {
+			return nil, fmt.Errorf("can not rename both %s and %s to %s", other, from, to)
+		}
+		targets[to] = from
+
+		if _, ok := os.LookupEnv(from); !ok {
+			continue
+		}
+		if _, ok := os.LookupEnv(to); ok {
+			return nil, fmt.Errorf("can not rename %s to %s in the env: %s is already set", from, to, to)
+		}
+	}
+
+	if !m.dryRun {
+		unlock, err := lockEdits(m.files)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
+	// read every file first so a conflict stops the migration before anything is written, the files
+	// stay locked until the renames are written so no other write in between is lost
+	docs := make(map[string]*Document)
+	modes := make(map[string]os.FileMode)
+	originals := make(map[string][]byte)
+	for _, filename := range m.files {
+		f, mode, err := readLines(filename)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, &FileError{Filename: filename, Err: err}
+		}
+		doc := &Document{file: f}
+		originals[filename] = f.join()
+
+		for _, from := range froms {
+			if _, ok := doc.Lookup(from); !ok {
+				continue
+			}
+			if _, ok := doc.Lookup(renames[from]); ok {
+				return nil, fmt.Errorf("can not rename %s to %s in %s: %s is already set", from, renames[from], filename, renames[from])
+			}
+		}
+		docs[filename] = doc
+		modes[filename] = mode
+	}
+
+	var done, envRenames []Rename
+	var written []string
+
+	// undo puts the env and the files back to what they were before the migration
+	undo := func() {
+		if m.dryRun {
+			return
+		}
+
+		for i := len(envRenames) - 1; i >= 0; i-- {
+			r := envRenames[i]
+			os.Setenv(r.From, os.Getenv(r.To))
+			os.Unsetenv(r.To)
+			l.renameLoaded(r.To, r.From)
+		}
+
+		for _, filename := range written {
+			writeFileAtomic(filename, originals[filename], modes[filename])
+		}
+	}
+
+	for _, from := range froms {
+		to := renames[from]
+		val, ok := os.LookupEnv(from)
+		if !ok {
+			continue
+		}
+
+		if !m.dryRun {
+			err := os.Setenv(to, val)
+			if err != nil {
+				undo()
+				return nil, err
+			}
+			os.Unsetenv(from)
+			l.renameLoaded(from, to)
+		}
+		envRenames = append(envRenames, Rename{From: from, To: to, Where: "env"})
+	}
+	done = append(done, envRenames...)
+
+	for _, filename := range m.files {
+		doc, ok := docs[filename]
+		if !ok {
+			continue
+		}
+
+		var renamed []Rename
+		for _, from := range froms {
+			if ok, _ := doc.Rename(from, renames[from]); ok {
+				renamed = append(renamed, Rename{From: from, To: renames[from], Where: filename})
+			}
+		}
+
+		if len(renamed) != 0 && !m.dryRun {
+			err := writeFileAtomic(filename, doc.Bytes(), modes[filename])
+			if err != nil {
+				undo()
+				return nil, &FileError{Filename: filename, Err: err}
+			}
+			written = append(written, filename)
+		}
+		done = append(done, renamed...)
+	}
+
+	var pushed []Rename
+	for _, name := range m.adapters {
+		renamed, err := l.migrateAdapter(name, renames, froms, m.dryRun)
+		if err != nil {
+			undo()
+			return pushed, err
+		}
+		pushed = append(pushed, renamed...)
+	}
+
+	return append(done, pushed...), nil
+}
+
+// migrateAdapter pushes the keys of the adapter that are renamed under their new names
+func (l *Loader) migrateAdapter(name string, renames map[string]string, froms []string, dryRun bool) ([]Rename, error) {
+	a := l.adapter(name)
+	if a == nil {
+		return nil, fmt.Errorf("there is no adapter named %q", name)
+	}
+	if a.Push == nil {
+		return nil, &AdapterError{Adapter: name, Err: ErrNoPush}
+	}
+	if a.Network && l.config().offline {
+		return nil, &AdapterError{Adapter: name, Err: ErrOffline}
+	}
+
+	remote, err := a.pull(Environ())
+	if err != nil {
+		return nil, &AdapterError{Adapter: name, Err: err}
+	}
+
+	var renamed []Rename
+	pushed := NewMap()
+	for _, from := range froms {
+		if val, ok := remote.Map[from]; ok {
+			pushed.Set(renames[from], val)
+			renamed = append(renamed, Rename{From: from, To: renames[from], Where: name})
+		}
+	}
+
+	if len(renamed) == 0 || dryRun {
+		return renamed, nil
+	}
+
+	err = a.push(pushed)
+	if err != nil {
+		return nil, &AdapterError{Adapter: name, Err: err}
+	}
+
+	return renamed, nil
+}
+
+// renameLoaded renames the key in the keys the Loader has loaded, so LoadedKeys stays right
+func (l *Loader) renameLoaded(from, to string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.loaded == nil {
+		return
+	}
+
+	if val, ok := l.loaded.Map[from]; ok {
+		l.loaded.Set(to, val)
+		if info, ok := l.loaded.info[from]; ok {
+			l.loaded.info[to] = info
+		}
+		delete(l.loaded.info, from)
+		delete(l.loaded.Map, from)
+	}
+}
+
+// lockEdits locks the files for an edit like lockEdit, in the same order every time so two migrations
+// locking the same files can not each wait on the other
+func lockEdits(filenames []string) (unlock func(), err error) {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, filename := range filenames {
+		abs, err := editPath(filename)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[abs] {
+			seen[abs] = true
+			paths = append(paths, abs)
+		}
+	}
+	sort.Strings(paths)
+
+	var unlocks []func()
+	unlock = func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+
+	for _, path := range paths {
+		u, err := lockEdit(path)
+		if err != nil {
+			unlock()
+			return nil, &FileError{Filename: path, Err: err}
+		}
+		unlocks = append(unlocks, u)
+	}
+
+	return unlock, nil
+}
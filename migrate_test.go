@@ -0,0 +1,100 @@
+package env
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMigrateConflicts(t *testing.T) {
+	tests := []struct {
+		name    string
+		renames map[string]string
+		env     map[string]string
+		err     string
+	}{
+		{"new key set in the env", map[string]string{"MIGRATE_OLD": "MIGRATE_NEW"}, map[string]string{"MIGRATE_OLD": "1", "MIGRATE_NEW": "2"}, "MIGRATE_NEW is already set"},
+		{"two keys to one", map[string]string{"MIGRATE_A": "MIGRATE_NEW", "MIGRATE_B": "MIGRATE_NEW"}, map[string]string{"MIGRATE_A": "1"}, "can not rename both MIGRATE_A and MIGRATE_B"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, val := range tt.env {
+				os.Setenv(key, val)
+				defer os.Unsetenv(key)
+			}
+
+			path := writeEnvFile(t, "MIGRATE_OLD=1\n")
+			l := NewLoader()
+
+			_, err := l.Migrate(tt.renames, MigrateFiles(path))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("Migrate error = %v, want it to contain %q", err, tt.err)
+			}
+
+			// nothing was renamed
+			if got := os.Getenv("MIGRATE_OLD"); got != tt.env["MIGRATE_OLD"] {
+				t.Errorf("MIGRATE_OLD = %q, want %q", got, tt.env["MIGRATE_OLD"])
+			}
+			content, _ := ioutil.ReadFile(path)
+			if string(content) != "MIGRATE_OLD=1\n" {
+				t.Errorf("the file was changed to %q", content)
+			}
+		})
+	}
+}
+
+func TestMigrateUndo(t *testing.T) {
+	os.Setenv("MIGRATE_OLD", "1")
+	defer os.Unsetenv("MIGRATE_OLD")
+	defer os.Unsetenv("MIGRATE_NEW")
+
+	path := writeEnvFile(t, "# the old key\nMIGRATE_OLD=1\n")
+
+	var pushed *Map
+	l := NewLoader()
+	l.ApplyAdapter(&Adapter{
+		Name: "first",
+		Pull: func() (*Map, error) { return ParseBytes([]byte("MIGRATE_OLD=1")), nil },
+		Push: func(m *Map) error {
+			pushed = m
+			return nil
+		},
+	})
+	l.ApplyAdapter(&Adapter{
+		Name: "second",
+		Pull: func() (*Map, error) { return ParseBytes([]byte("MIGRATE_OLD=1")), nil },
+		Push: func(m *Map) error { return errors.New("store is down") },
+	})
+
+	renamed, err := l.Migrate(map[string]string{"MIGRATE_OLD": "MIGRATE_NEW"}, MigrateFiles(path), MigrateAdapters("first", "second"))
+	if err == nil {
+		t.Fatal("Migrate did not fail")
+	}
+
+	// the push to the first adapter is done, the env and the file are put back
+	if want := []Rename{{From: "MIGRATE_OLD", To: "MIGRATE_NEW", Where: "first"}}; !reflect.DeepEqual(renamed, want) {
+		t.Errorf("Migrate returned %v, want %v", renamed, want)
+	}
+	if pushed == nil || pushed.Map["MIGRATE_NEW"] != "1" {
+		t.Errorf("the first adapter was pushed %v, want MIGRATE_NEW=1", pushed)
+	}
+
+	if got, ok := os.LookupEnv("MIGRATE_OLD"); got != "1" || !ok {
+		t.Errorf("MIGRATE_OLD = %q, want 1", got)
+	}
+	if _, ok := os.LookupEnv("MIGRATE_NEW"); ok {
+		t.Error("MIGRATE_NEW is still set")
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "# the old key\nMIGRATE_OLD=1\n" {
+		t.Errorf("the file is %q, want it as it was", content)
+	}
+}
@@ -0,0 +1,69 @@
+package env
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+/*
+Nested returns the keys of the map as a tree, split on sep and lower cased, for config consumers that
+want structured config while the process still gets flat env vars
+
+	APP__DB__HOST=localhost
+	APP__DB__PORT=5432
+
+with the separator __ is {"app": {"db": {"host": "localhost", "port": "5432"}}}. Values stay strings.
+A key that is both a value and the parent of other keys, like APP__DB next to APP__DB__HOST, is an
+error. Flatten turns the tree back into keys
+*/
+func (e *Map) Nested(sep string) (map[string]interface{}, error) {
+	if sep == "" {
+		return nil, fmt.Errorf("the separator of nested keys can not be empty")
+	}
+
+	tree := make(map[string]interface{})
+
+	for _, key := range e.Keys() {
+		parts := strings.Split(strings.ToLower(key), sep)
+
+		node := tree
+		for i, part := range parts {
+			if part == "" {
+				return nil, fmt.Errorf("can not nest %s: it has an empty part between separators", key)
+			}
+
+			if i == len(parts)-1 {
+				if _, ok := node[part]; ok {
+					return nil, fmt.Errorf("can not nest %s: other keys are nested under it", key)
+				}
+				node[part] = e.Map[key]
+				break
+			}
+
+			switch child := node[part].(type) {
+			case nil:
+				next := make(map[string]interface{})
+				node[part] = next
+				node = next
+			case map[string]interface{}:
+				node = child
+			default:
+				return nil, fmt.Errorf("can not nest %s: %s is set to a value", key, strings.ToUpper(strings.Join(parts[:i+1], sep)))
+			}
+		}
+	}
+
+	return tree, nil
+}
+
+// Flatten turns a tree, like the one Nested returns or any value that marshals to a JSON object, into
+// keys joined with sep and upper cased, the same way nested JSON files are loaded
+func Flatten(tree interface{}, sep string) (*Map, error) {
+	data, err := json.Marshal(tree)
+	if err != nil {
+		return nil, fmt.Errorf("can not flatten: %s", err)
+	}
+
+	return parseJSON(data, sep)
+}
@@ -0,0 +1,246 @@
+package env
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TokenSource returns the OIDC identity token of the workload
+type TokenSource func() (string, error)
+
+// TokenFile reads the token from a file, like the projected service account token in kubernetes
+func TokenFile(path string) TokenSource {
+	return func() (string, error) {
+		bytes, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("could not read identity token: %s", err)
+		}
+
+		return strings.TrimSpace(string(bytes)), nil
+	}
+}
+
+// GCEIdentityToken gets a token for the audience from the GCE metadata server
+func GCEIdentityToken(audience string) TokenSource {
+	return func() (string, error) {
+		query := url.Values{"audience": {audience}, "format": {"full"}}
+
+		req, err := http.NewRequest(http.MethodGet, "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/identity?"+query.Encode(), nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Metadata-Flavor", "Google")
+
+		token, err := doMetadata(&http.Client{Timeout: 2 * time.Second}, req)
+		if err != nil {
+			return "", fmt.Errorf("could not get identity token from the GCE metadata server: %s", err)
+		}
+
+		return token, nil
+	}
+}
+
+// AWSWebIdentityOptions configures AWSWebIdentity, anything left empty falls back to the env vars the AWS SDKs use,
+// looked up in what was loaded before the adapter first like AWSOptions
+type AWSWebIdentityOptions struct {
+	// RoleARN is the role to assume, by default $AWS_ROLE_ARN
+	RoleARN string
+
+	// SessionName names the session, by default $AWS_ROLE_SESSION_NAME or "env"
+	SessionName string
+
+	// Region picks the regional STS endpoint, by default the global one
+	Region string
+
+	// Token is the identity token, by default read from $AWS_WEB_IDENTITY_TOKEN_FILE
+	Token TokenSource
+
+	// Client is used for the request, by default a client with a 10 second timeout
+	Client *http.Client
+
+	// Endpoint overrides the STS endpoint, mostly for testing
+	Endpoint string
+}
+
+/*
+AWSWebIdentity returns an adapter that exchanges the workload's OIDC token for temporary AWS credentials
+with STS AssumeRoleWithWebIdentity, and exports them as AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+AWS_SESSION_TOKEN (and AWS_CREDENTIAL_EXPIRATION)
+*/
+func AWSWebIdentity(opts AWSWebIdentityOptions) *Adapter {
+	return awsAdapter("aws-web-identity", func(loaded Reader) (*Map, error) {
+		roleARN := firstNonEmpty(opts.RoleARN, getenv(loaded, "AWS_ROLE_ARN"))
+		if roleARN == "" {
+			return nil, fmt.Errorf("could not assume role: no RoleARN given and AWS_ROLE_ARN is not set")
+		}
+
+		getToken := opts.Token
+		if getToken == nil {
+			file := getenv(loaded, "AWS_WEB_IDENTITY_TOKEN_FILE")
+			if file == "" {
+				return nil, fmt.Errorf("could not assume role: no Token given and AWS_WEB_IDENTITY_TOKEN_FILE is not set")
+			}
+			getToken = TokenFile(file)
+		}
+
+		token, err := getToken()
+		if err != nil {
+			return nil, err
+		}
+
+		endpoint := opts.Endpoint
+		if endpoint == "" {
+			endpoint = "https://sts.amazonaws.com"
+			if opts.Region != "" {
+				endpoint = "https://sts." + opts.Region + ".amazonaws.com"
+			}
+		}
+
+		form := url.Values{
+			"Action":           {"AssumeRoleWithWebIdentity"},
+			"Version":          {"2011-06-15"},
+			"RoleArn":          {roleARN},
+			"RoleSessionName":  {firstNonEmpty(opts.SessionName, getenv(loaded, "AWS_ROLE_SESSION_NAME"), "env")},
+			"WebIdentityToken": {token},
+		}
+
+		client := opts.Client
+		if client == nil {
+			client = &http.Client{Timeout: 10 * time.Second}
+		}
+
+		resp, err := client.PostForm(endpoint, form)
+		if err != nil {
+			return nil, fmt.Errorf("could not assume role %s: %s", roleARN, err)
+		}
+		defer resp.Body.Close()
+
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("could not assume role %s: %s", roleARN, err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			var stsErr struct {
+				Code    string `xml:"Error>Code"`
+				Message string `xml:"Error>Message"`
+			}
+			xml.Unmarshal(body, &stsErr)
+
+			return nil, fmt.Errorf("could not assume role %s: %s %s: %s", roleARN, resp.Status, stsErr.Code, stsErr.Message)
+		}
+
+		var result struct {
+			Credentials struct {
+				AccessKeyID     string `xml:"AccessKeyId"`
+				SecretAccessKey string
+				SessionToken    string
+				Expiration      string
+			} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+		}
+		err = xml.Unmarshal(body, &result)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse AssumeRoleWithWebIdentity response: %s", err)
+		}
+
+		emap := NewMap()
+		emap.Set("AWS_ACCESS_KEY_ID", result.Credentials.AccessKeyID)
+		emap.Set("AWS_SECRET_ACCESS_KEY", result.Credentials.SecretAccessKey)
+		emap.Set("AWS_SESSION_TOKEN", result.Credentials.SessionToken)
+		emap.Set("AWS_CREDENTIAL_EXPIRATION", result.Credentials.Expiration)
+
+		return emap, nil
+	})
+}
+
+// VaultJWTOptions configures VaultJWT
+type VaultJWTOptions struct {
+	// Addr is the address of vault, by default VAULT_ADDR from what was loaded before the adapter
+	Addr string
+
+	// Mount is where the jwt auth method is mounted, by default "jwt"
+	Mount string
+
+	// Role is the vault role to log in as
+	Role string
+
+	// Token is the identity token to log in with
+	Token TokenSource
+
+	// Client is used for the request, by default a client with a 10 second timeout
+	Client *http.Client
+}
+
+// VaultJWT returns an adapter that logs in to vault with the workload's OIDC token using the jwt auth method,
+// and exports the vault token as VAULT_TOKEN (and VAULT_ADDR)
+func VaultJWT(opts VaultJWTOptions) *Adapter {
+	return loadedAdapter("vault-jwt", true, func(loaded Reader) (*Map, error) {
+		addr := strings.TrimSuffix(firstNonEmpty(opts.Addr, getenv(loaded, "VAULT_ADDR")), "/")
+		if addr == "" {
+			return nil, fmt.Errorf("could not log in to vault: no Addr given and VAULT_ADDR is not set")
+		}
+
+		if opts.Token == nil {
+			return nil, fmt.Errorf("could not log in to vault: no Token given")
+		}
+
+		token, err := opts.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := json.Marshal(map[string]string{"role": opts.Role, "jwt": token})
+		if err != nil {
+			return nil, err
+		}
+
+		client := opts.Client
+		if client == nil {
+			client = &http.Client{Timeout: 10 * time.Second}
+		}
+
+		resp, err := client.Post(addr+"/v1/auth/"+firstNonEmpty(opts.Mount, "jwt")+"/login", "application/json", bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("could not log in to vault: %s", err)
+		}
+		defer resp.Body.Close()
+
+		var result struct {
+			Errors []string `json:"errors"`
+			Auth   struct {
+				ClientToken string `json:"client_token"`
+			} `json:"auth"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		if err != nil && resp.StatusCode == http.StatusOK {
+			return nil, fmt.Errorf("could not parse vault login response: %s", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("could not log in to vault: %s %s", resp.Status, strings.Join(result.Errors, ", "))
+		}
+
+		emap := NewMap()
+		emap.Set("VAULT_ADDR", addr)
+		emap.Set("VAULT_TOKEN", result.Auth.ClientToken)
+
+		return emap, nil
+	})
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, val := range values {
+		if val != "" {
+			return val
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,332 @@
+package env
+
+import "time"
+
+// settings is the configuration of a Loader, a copy is taken at the start of every load so
+// Configure can change it while other goroutines are loading
+type settings struct {
+	filenames []string
+	logf      func(format string, args ...interface{})
+	envKey    string
+	parser    parser
+	expand    bool
+	strict    bool
+
+	// localFiles loads the <name>.local shadow file of every file
+	localFiles bool
+
+	// files holds the options given to File, by filename
+	files map[string][]FileOption
+
+	schema *Schema
+
+	// nestedSep joins nested keys, see WithNestedSeparator
+	nestedSep string
+
+	// commands runs the $(command) parts of values when it is set, see WithCommands
+	commands *commandSettings
+
+	// sanitize cleans up the values, see WithSanitize
+	sanitize bool
+
+	// warnings logs the values that look like mistakes, see WithWarnings
+	warnings bool
+
+	// allowEmpty lets required keys be set to an empty value, see WithAllowEmpty
+	allowEmpty bool
+
+	// offline skips the adapters that need the network, see WithOffline
+	offline bool
+
+	// base64 decodes the base64: values, see WithBase64
+	base64 bool
+
+	// fileRefs reads the files of file:// values, see WithFileRefs
+	fileRefs bool
+}
+
+func defaultSettings() settings {
+	return settings{
+		filenames: envFileNames,
+		logf:      func(string, ...interface{}) {},
+		envKey:    "APP_ENV",
+		parser:    defaultParser(),
+		nestedSep: "_",
+	}
+}
+
+// DuplicatePolicy decides what happens when a key is set again by a later line or file, see WithDuplicates
+type DuplicatePolicy int
+
+const (
+	// LastWins lets the later value override the earlier one, which is the default
+	LastWins DuplicatePolicy = iota
+	// FirstWins keeps the first value and ignores the later ones
+	FirstWins
+	// FailOnDuplicate fails the load with a *DuplicateError
+	FailOnDuplicate
+)
+
+// Option configures a Loader
+type Option func(*settings)
+
+// WithFiles sets the files that are loaded when Load is called without any, by default that is `.env`
+func WithFiles(filenames ...string) Option {
+	return func(s *settings) {
+		s.filenames = filenames
+	}
+}
+
+// WithLogger sets where the Loader reports files it skipped, a Loader does not log anything by default
+func WithLogger(logf func(format string, args ...interface{})) Option {
+	return func(s *settings) {
+		s.logf = logf
+	}
+}
+
+// WithAppendSeparator sets what KEY+=value puts between the old and new value, by default that is a comma
+func WithAppendSeparator(sep string) Option {
+	return func(s *settings) {
+		s.parser.appendSep = sep
+	}
+}
+
+// WithDuplicates sets what happens when a key is set again in the same file or a later one, by default the
+// last value wins. KEY?=value and KEY+=value build on earlier values on purpose so they are always allowed,
+// and so are the overrides in `.local` shadow files (see WithLocalFiles)
+func WithDuplicates(policy DuplicatePolicy) Option {
+	return func(s *settings) {
+		s.parser.duplicates = policy
+	}
+}
+
+// WithValidKeys fails the load with a *KeyError when a file or adapter sets a key that is not a valid
+// POSIX env var name, made of letters, digits and underscores and not starting with a digit. Without it
+// a line like `my key=foo` sets a key that most programs can not read
+func WithValidKeys() Option {
+	return func(s *settings) {
+		s.parser.validKeys = true
+	}
+}
+
+// WithWarnings logs a warning for every loaded value that looks like a mistake, like a URL with a misspelled
+// scheme or a port out of range, see Suspicious. The warnings go to the logger set with WithLogger
+func WithWarnings() Option {
+	return func(s *settings) {
+		s.warnings = true
+	}
+}
+
+/*
+WithCommands replaces $(command) in the values of env files with the output of the command, like
+GIT_SHA=$(git rev-parse HEAD). Anyone who can write an env file can then run programs, so it is off
+by default and only the programs in allowed run, every other one fails the load with a *CommandError.
+Commands are not run by a shell, so pipes and other shell syntax do not work. Each command can run
+for timeout, 10 seconds if it is 0
+
+	env.WithCommands(5*time.Second, "git", "hostname")
+*/
+func WithCommands(timeout time.Duration, allowed ...string) Option {
+	return func(s *settings) {
+		s.commands = &commandSettings{timeout: timeout, allowed: allowed}
+	}
+}
+
+// WithSanitize trims the whitespace around loaded values and strips quotes wrapping them, see Sanitize.
+// Every change is logged to the logger set with WithLogger
+func WithSanitize() Option {
+	return func(s *settings) {
+		s.sanitize = true
+	}
+}
+
+// WithOffline skips every adapter with Network set, logging that it did, so a build or test in CI
+// without network access loads the same env every time. A skipped adapter that is Required fails
+// the load with an *AdapterError wrapping ErrOffline, and Push and Migrate refuse network adapters
+func WithOffline() Option {
+	return func(s *settings) {
+		s.offline = true
+	}
+}
+
+// WithBase64 decodes values starting with base64: (see Base64Prefix) from files and adapters before they are set.
+// It is off by default since some frameworks, like Laravel with its APP_KEY, decode the prefix themselves
+func WithBase64() Option {
+	return func(s *settings) {
+		s.base64 = true
+	}
+}
+
+// WithFileRefs replaces values starting with file:// (see FilePrefix) from files and adapters with the contents
+// of the file, like secrets Docker and Kubernetes mount as files. A file that can not be read fails the load
+func WithFileRefs() Option {
+	return func(s *settings) {
+		s.fileRefs = true
+	}
+}
+
+// WithEnvironmentKey sets the env var holding the current environment, which is checked against the
+// environment in the metadata header of files. By default that is APP_ENV
+func WithEnvironmentKey(key string) Option {
+	return func(s *settings) {
+		s.envKey = key
+	}
+}
+
+/*
+WithExpand turns on expanding ${VAR} references in the values of env files. References are resolved
+against every loaded key (from files and adapters) and then the env. Values from adapters are never
+expanded themselves, secrets are used as they are. Write \$ or $$ for a literal dollar sign
+*/
+func WithExpand() Option {
+	return func(s *settings) {
+		s.expand = true
+	}
+}
+
+// WithStrictExpand turns on expanding like WithExpand, but a reference to a key that is not set anywhere
+// fails the load with an *UndefinedError listing all of them instead of expanding to nothing
+func WithStrictExpand() Option {
+	return func(s *settings) {
+		s.expand = true
+		s.strict = true
+	}
+}
+
+// WithDialect sets the syntax rules used to parse env files, by default that is DialectDefault.
+// It also turns expanding on or off to match the dialect, so put WithExpand after it to change that
+func WithDialect(d Dialect) Option {
+	return func(s *settings) {
+		s.parser.Dialect = d
+		s.expand = d.Expand
+	}
+}
+
+// WithNestedSeparator sets what joins the keys of nested mappings in YAML, JSON and TOML files and the
+// parts of dotted .properties keys, by default an underscore so database.host is DATABASE_HOST
+func WithNestedSeparator(sep string) Option {
+	return func(s *settings) {
+		s.nestedSep = sep
+	}
+}
+
+/*
+WithSchema checks every load against the schema: keys that are not set get their default, deprecated
+keys that are set are reported to the logger (see WithLogger) and the load fails with a *SchemaError
+when the result does not match, which includes deprecated keys past their removal date. Keys set in
+the env count too
+*/
+func WithSchema(schema *Schema) Option {
+	return func(s *settings) {
+		s.schema = schema
+	}
+}
+
+/*
+WithLocalFiles also loads the `<name>.local` file of every file that is loaded, if it exists, right after
+the file so its values win. That makes `.env.local` the place for overrides that are not checked in
+
+	.env        checked in
+	.env.local  in .gitignore
+
+Loader.LoadedFiles tells what was read
+*/
+func WithLocalFiles() Option {
+	return func(s *settings) {
+		s.localFiles = true
+	}
+}
+
+// WithAllowEmpty makes MustLoad and MustLoadSecrets accept required keys that are set to an empty value,
+// without it only keys that are empty on purpose (see Map.IsEmpty) pass
+func WithAllowEmpty() Option {
+	return func(s *settings) {
+		s.allowEmpty = true
+	}
+}
+
+// FileOption changes how a single file is loaded, see File
+type FileOption func(*fileSettings)
+
+// fileSettings are the settings that can be changed for a single file, they start out as the Loader's
+type fileSettings struct {
+	expand bool
+	strict bool
+}
+
+/*
+File adds a file to the files that are loaded when Load is called without any, with options that
+only apply to that file. The options are also used when the file is passed to Load directly
+
+	env.Configure(
+		env.File(".env.local", env.NoExpand()),
+		env.File("secrets.env", env.Strict()),
+	)
+
+With the default `.env` that loads .env, then .env.local and then secrets.env
+*/
+func File(filename string, opts ...FileOption) Option {
+	return func(s *settings) {
+		if !hasString(s.filenames, filename) {
+			s.filenames = append(append([]string(nil), s.filenames...), filename)
+		}
+
+		// copy the map since earlier copies of the settings can still be in use by a load
+		files := make(map[string][]FileOption, len(s.files)+1)
+		for name, fileOpts := range s.files {
+			files[name] = fileOpts
+		}
+		files[filename] = append(append([]FileOption(nil), files[filename]...), opts...)
+
+		s.files = files
+	}
+}
+
+// Expand turns on expanding ${VAR} references in the file, see WithExpand
+func Expand() FileOption {
+	return func(f *fileSettings) {
+		f.expand = true
+	}
+}
+
+// NoExpand keeps the values of the file as they are, even when expanding is turned on for the Loader
+func NoExpand() FileOption {
+	return func(f *fileSettings) {
+		f.expand = false
+		f.strict = false
+	}
+}
+
+// Strict expands the file like WithStrictExpand, references in it to keys that are not set fail the load
+func Strict() FileOption {
+	return func(f *fileSettings) {
+		f.expand = true
+		f.strict = true
+	}
+}
+
+// file returns the settings of the file, the Loader's with the options given to File for it on top
+func (s settings) file(filename string) fileSettings {
+	f := fileSettings{expand: s.expand, strict: s.strict}
+
+	for _, opt := range s.files[filename] {
+		opt(&f)
+	}
+
+	return f
+}
+
+// expands reports if any file is expanded
+func (s settings) expands() bool {
+	if s.expand {
+		return true
+	}
+
+	for filename := range s.files {
+		if s.file(filename).expand {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,54 @@
+package env
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is returned when an adapter, hook or derived key panics while the env loads, so one bug in
+// them fails the load instead of taking the process down. Name says what panicked and Stack is where
+type PanicError struct {
+	Name  string
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%s panicked: %v", e.Name, e.Value)
+}
+
+// Unwrap returns the value of the panic when it was an error
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// recoverPanic turns a panic into a *PanicError in err, it has to be deferred directly
+func recoverPanic(name string, err *error) {
+	if v := recover(); v != nil {
+		*err = &PanicError{Name: name, Value: v, Stack: debug.Stack()}
+	}
+}
+
+// pull runs PullFrom or else Pull, turning a panic into an error and a nil map into an empty one
+func (a *Adapter) pull(loaded Reader) (emap *Map, err error) {
+	defer recoverPanic("Pull", &err)
+
+	if a.PullFrom != nil {
+		emap, err = a.PullFrom(loaded)
+	} else {
+		emap, err = a.Pull()
+	}
+	if emap == nil && err == nil {
+		emap = NewMap()
+	}
+
+	return emap, err
+}
+
+// push runs Push, turning a panic into an error
+func (a *Adapter) push(emap *Map) (err error) {
+	defer recoverPanic("Push", &err)
+
+	return a.Push(emap)
+}
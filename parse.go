@@ -0,0 +1,730 @@
+package env
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// parser holds the settings used when parsing env files
+type parser struct {
+	Dialect
+
+	// appendSep is put between the old and new value by KEY+=value
+	appendSep string
+
+	// duplicates is what happens when a key is set again with =
+	duplicates DuplicatePolicy
+
+	// validKeys fails on keys that are not valid env var names, see WithValidKeys
+	validKeys bool
+
+	// strict collects the lines that do not parse in problems instead of skipping them
+	strict   bool
+	problems []LineError
+}
+
+func defaultParser() parser {
+	return parser{Dialect: DialectDefault, appendSep: ","}
+}
+
+/*
+Metadata is the optional header at the top of an env file, written like yaml front matter
+
+	---
+	schema: 1
+	environment: production
+	owner: platform-team
+	---
+
+Load refuses to load a file whose environment does not match the APP_ENV env var
+*/
+type Metadata map[string]string
+
+// ParseMetadata returns the metadata header of the content, or nil if it does not have one
+func ParseMetadata(content string) Metadata {
+	meta, _ := readHeader(newLineReader(strings.NewReader(content)))
+
+	return meta
+}
+
+// Parse parses the content of an env file and returns a env map, see ParseReader to parse from a io.Reader
+func Parse(content string) *Map {
+	emap, _ := ParseReader(strings.NewReader(content))
+
+	return emap
+}
+
+// ParseBytes parses the content of an env file and returns a env map
+func ParseBytes(content []byte) *Map {
+	emap, _ := ParseReader(bytes.NewReader(content))
+
+	return emap
+}
+
+/*
+ParseStrict parses the content of an env file like Parse, but every line that does not parse is an
+error instead of being skipped: lines without a =, keys that are not valid env var names and quotes
+that are never closed. The error is a *SyntaxError listing each of them with its line number, the
+map still holds the lines that did parse
+*/
+func ParseStrict(content string) (*Map, error) {
+	emap := NewMap()
+
+	p := defaultParser()
+	p.strict = true
+	p.parse(emap, strings.NewReader(content), "")
+
+	if len(p.problems) != 0 {
+		return emap, &SyntaxError{Errors: p.problems}
+	}
+
+	return emap, nil
+}
+
+// ParseReader parses an env file line by line from a reader, so a connection or pipe is not read into memory first.
+// The error is only ever from reading, the map holds the lines read before it
+func ParseReader(r io.Reader) (*Map, error) {
+	emap := NewMap()
+
+	p := defaultParser()
+	_, err := p.parse(emap, r, "")
+
+	return emap, err
+}
+
+// ParseFrom is ParseReader
+func ParseFrom(r io.Reader) (*Map, error) {
+	return ParseReader(r)
+}
+
+/*
+parse reads the lines of r into emap following the dialect. Besides KEY=value (and KEY:=value,
+which is the same) a line can use the operators:
+
+	KEY?=value  sets KEY only if it was not set by an earlier line, file or the env
+	KEY+=value  appends value to what KEY was set to by an earlier line, file or the env
+
+Values can be wrapped in single or double quotes, which are removed, single quoted values are taken
+literally and never expanded. A quoted value can span lines until its closing quote. A value ending
+with a backslash is continued on the next line, and the comment lines right above a key are kept as
+its description. Comments like `# @tag:database,secret` tag the key below them.
+A leading export is ignored so files written to be sourced by a shell load as they are.
+
+parse works on a map that may already hold earlier files so the operators work across layered files.
+Lines without a = are skipped. Keys set by the content get source as their source, and its metadata
+header is returned if it has one
+*/
+func (p *parser) parse(emap *Map, r io.Reader, source string) (Metadata, error) {
+	lines := newLineReader(r)
+
+	meta, err := readHeader(lines)
+	if err != nil {
+		return nil, err
+	}
+
+	// comment and tags hold the comment lines right above the current line
+	var comment, tags []string
+
+	for {
+		line, ok := lines.next()
+		if !ok {
+			break
+		}
+		// the spaces at the end of a line opening a quote are part of the value
+		padded := p.trimLeft(line)
+		line = p.trim(line)
+		number := lines.n
+
+		if line == "" {
+			comment, tags = nil, nil
+			continue
+		}
+
+		if p.isComment(line) {
+			text := strings.TrimSpace(line[1:])
+
+			if strings.HasPrefix(text, "@tag:") {
+				tags = append(tags, parseTags(text)...)
+			} else {
+				comment = append(comment, text)
+			}
+			continue
+		}
+
+		// a heredoc takes the lines up to its delimiter as they are
+		if key, op, val, ok := p.split(line); ok && p.Heredocs {
+			body, literal, isHeredoc, closed := readHeredoc(lines, val)
+
+			if isHeredoc && !closed && p.strict {
+				p.problems = append(p.problems, LineError{Line: number, Text: line, Message: "unterminated heredoc"})
+				comment, tags = nil, nil
+				continue
+			}
+
+			if closed {
+				if problem := p.check(key, "", true); p.strict && problem != "" {
+					p.problems = append(p.problems, LineError{Line: number, Text: line, Message: problem})
+				} else if err := p.set(emap, key, op, body, literal, source, number, comment, tags); err != nil {
+					return meta, err
+				}
+				comment, tags = nil, nil
+				continue
+			}
+		}
+
+		// a trailing backslash continues the value on the next line
+		for p.Continuation && continues(line) {
+			next, ok := lines.next()
+			if !ok {
+				break
+			}
+
+			line = line[:len(line)-1] + p.trim(next)
+			padded = line
+		}
+
+		// a quoted value goes on until its closing quote, taking the line breaks with it
+		if _, _, val, ok := p.split(line); ok && p.Quotes && opensQuote(val) {
+			q := newQuoteScanner(val)
+
+			var more []string
+			for {
+				next, ok := lines.next()
+				if !ok {
+					// never closed, so the quote is just part of the value
+					lines.unread(more...)
+					break
+				}
+				more = append(more, next)
+
+				if q.closes(next) {
+					line = padded + "\n" + strings.Join(more, "\n")
+					break
+				}
+			}
+		}
+
+		key, op, val, ok := p.split(line)
+		if p.strict {
+			if problem := p.check(key, val, ok); problem != "" {
+				p.problems = append(p.problems, LineError{Line: number, Text: line, Message: problem})
+				comment, tags = nil, nil
+				continue
+			}
+		}
+
+		if ok {
+			if p.SplitAfterQuote {
+				var rest string
+				if val, rest = p.cutQuoted(val); rest != "" {
+					lines.unread(rest)
+				}
+			}
+
+			raw := strings.TrimLeft(val, " \t")
+			val, literal := p.value(val)
+
+			err := p.set(emap, key, op, val, literal, source, number, comment, tags)
+			if err != nil {
+				return meta, err
+			}
+
+			// KEY="" is empty on purpose, KEY= was left blank
+			if set, has := emap.Map[key]; has && set == "" && val == "" && raw != "" && (raw[0] == '"' || raw[0] == '\'') {
+				emap.keyInfo(key).empty = true
+			}
+		}
+		comment, tags = nil, nil
+	}
+
+	return meta, lines.readErr()
+}
+
+// set assigns the value on line number with the comment and tags above it
+func (p *parser) set(emap *Map, key, op, val string, literal bool, source string, number int, comment, tags []string) error {
+	if p.validKeys && !isName(key) {
+		return &KeyError{Key: key, Source: source, Line: number}
+	}
+
+	if op == "=" || op == ":=" {
+		skip, err := p.duplicate(emap, key, source, number)
+		if err != nil || skip {
+			return err
+		}
+	}
+
+	if p.assign(emap, key, op, val) {
+		emap.SetSource(key, source)
+		emap.keyInfo(key).literal = literal
+		emap.keyInfo(key).empty = false
+	}
+
+	if len(comment) != 0 {
+		emap.SetDescription(key, strings.Join(comment, "\n"))
+	}
+	emap.AddTags(key, tags...)
+
+	return nil
+}
+
+// duplicate applies the duplicate policy to a key set again on line number of source, skip is true if the new value is ignored
+func (p *parser) duplicate(emap *Map, key, source string, number int) (skip bool, err error) {
+	if _, ok := emap.Map[key]; !ok || p.duplicates == LastWins {
+		return false, nil
+	}
+
+	if p.duplicates == FirstWins {
+		return true, nil
+	}
+
+	return true, &DuplicateError{Key: key, First: emap.Source(key), Source: source, Line: number}
+}
+
+/*
+readHeredoc reads the body of a heredoc value like a shell does, isHeredoc is false if the value does
+not start one. The body is every line up to the one holding only the delimiter, taken as it is.
+<<-EOF strips the tabs the lines start with and a quoted delimiter like <<'EOF' keeps the body from
+being expanded. closed is false if the delimiter never comes, then the lines are left to be parsed
+*/
+func readHeredoc(lines *lineReader, val string) (body string, literal, isHeredoc, closed bool) {
+	delim := strings.TrimSpace(val)
+	if !strings.HasPrefix(delim, "<<") {
+		return "", false, false, false
+	}
+	delim = delim[2:]
+
+	strip := strings.HasPrefix(delim, "-")
+	delim = strings.TrimPrefix(delim, "-")
+
+	if n := len(delim); n >= 2 && (delim[0] == '\'' || delim[0] == '"') && delim[n-1] == delim[0] {
+		delim, literal = delim[1:n-1], true
+	}
+	if !isName(delim) {
+		return "", false, false, false
+	}
+
+	var read, bodyLines []string
+	for {
+		next, ok := lines.next()
+		if !ok {
+			lines.unread(read...)
+			return "", false, true, false
+		}
+		read = append(read, next)
+
+		if strip {
+			next = strings.TrimLeft(next, "\t")
+		}
+		if strings.TrimRight(next, " \t") == delim {
+			return strings.Join(bodyLines, "\n"), literal, true, true
+		}
+		bodyLines = append(bodyLines, next)
+	}
+}
+
+// check returns what is wrong with a line in strict mode, if anything
+func (p *parser) check(key, val string, ok bool) string {
+	switch {
+	case !ok:
+		return "missing ="
+	case !isName(key):
+		return fmt.Sprintf("invalid key %q", key)
+	case p.Quotes && opensQuote(val):
+		return "unterminated quote"
+	}
+
+	return ""
+}
+
+// lineReader reads lines one at a time, lines can be pushed back to be read again
+type lineReader struct {
+	r       *bufio.Reader
+	pending []string
+	err     error
+
+	// n is the number of the line last read, counting from 1
+	n int
+}
+
+func newLineReader(r io.Reader) *lineReader {
+	return &lineReader{r: bufio.NewReader(r)}
+}
+
+// next returns the next line without its line break, ok is false once there are no lines left or reading failed
+func (lr *lineReader) next() (string, bool) {
+	if n := len(lr.pending); n != 0 {
+		line := lr.pending[n-1]
+		lr.pending = lr.pending[:n-1]
+		lr.n++
+		return line, true
+	}
+
+	if lr.err != nil {
+		return "", false
+	}
+
+	line, err := lr.r.ReadString('\n')
+	if err != nil {
+		if err != io.EOF {
+			lr.err = err
+			return "", false
+		}
+
+		// mark the end, but still return the last line if it did not end with a line break
+		lr.err = io.EOF
+		if line == "" {
+			return "", false
+		}
+	}
+
+	// files saved on Windows start with a byte order mark and end lines with \r\n
+	if lr.n == 0 {
+		line = strings.TrimPrefix(line, bom)
+	}
+	lr.n++
+
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), true
+}
+
+// bom is the UTF-8 byte order mark
+const bom = "\ufeff"
+
+// unread pushes lines back, they are read again in the order given
+func (lr *lineReader) unread(lines ...string) {
+	for i := len(lines) - 1; i >= 0; i-- {
+		lr.pending = append(lr.pending, lines[i])
+	}
+	lr.n -= len(lines)
+}
+
+// readErr is the error that stopped reading, if it was not the end of the input
+func (lr *lineReader) readErr() error {
+	if lr.err == io.EOF {
+		return nil
+	}
+
+	return lr.err
+}
+
+// readHeader reads the metadata header if the first line that is not blank is ---, otherwise it reads nothing
+func readHeader(lines *lineReader) (Metadata, error) {
+	var read []string
+
+	for {
+		line, ok := lines.next()
+		if !ok {
+			lines.unread(read...)
+			return nil, lines.readErr()
+		}
+		read = append(read, line)
+
+		line = strings.Trim(line, " ")
+		if line == "" {
+			continue
+		}
+		if line == "---" {
+			break
+		}
+
+		lines.unread(read...)
+		return nil, nil
+	}
+
+	meta := Metadata{}
+	for {
+		line, ok := lines.next()
+		if !ok {
+			// no closing ---, so it was not a header after all
+			lines.unread(read...)
+			return nil, lines.readErr()
+		}
+		read = append(read, line)
+
+		line = strings.Trim(line, " ")
+		if line == "---" {
+			return meta, nil
+		}
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		splitLine := strings.SplitN(line, ":", 2)
+		if len(splitLine) == 2 {
+			meta[strings.TrimSpace(splitLine[0])] = strings.Trim(strings.TrimSpace(splitLine[1]), `"'`)
+		}
+	}
+}
+
+// value unquotes a quoted value or strips the inline comment off a value that is not quoted,
+// literal is true for a single quoted value
+func (p *parser) value(val string) (string, bool) {
+	if p.TrimSpace {
+		val = strings.TrimFunc(val, isSpace)
+	}
+
+	if quoted := strings.TrimLeft(val, " \t"); p.Quotes && len(quoted) >= 2 && (quoted[0] == '"' || quoted[0] == '\'') {
+		if end := closingQuote(quoted); end != -1 {
+			// only a comment can follow the closing quote, otherwise the quotes are part of the value
+			if rest := strings.TrimSpace(quoted[end+1:]); rest == "" || p.isComment(rest) {
+				if quoted[0] == '\'' {
+					return quoted[1:end], true
+				}
+				if p.BackslashEscapes {
+					return unescapeAny(quoted[1:end])
+				}
+
+				return unescapeOnly(quoted[1:end], p.Escapes), false
+			}
+		}
+	}
+
+	val = p.stripInlineComment(val)
+	if p.TrimSpace {
+		val = strings.TrimFunc(val, isSpace)
+	}
+	if p.BackslashEscapes && strings.Contains(val, `\$`) {
+		return strings.Replace(val, `\$`, "$", -1), true
+	}
+
+	return val, false
+}
+
+// cutQuoted cuts what follows the closing quote of a quoted value off, unless it is only a comment
+func (p *parser) cutQuoted(val string) (quoted, rest string) {
+	quoted = p.trimLeft(val)
+	if !p.Quotes || quoted == "" || (quoted[0] != '"' && quoted[0] != '\'') {
+		return val, ""
+	}
+
+	end := closingQuote(quoted)
+	if end == -1 {
+		return val, ""
+	}
+
+	if rest = p.trim(quoted[end+1:]); rest == "" || p.isComment(rest) {
+		return val, ""
+	}
+
+	return quoted[:end+1], rest
+}
+
+// closingQuote returns the index of the quote closing the quote at the start of s, or -1.
+// A backslash escapes a double quote, single quoted values have no escapes
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && s[0] == '"':
+			i++
+		case s[i] == s[0]:
+			return i
+		}
+	}
+
+	return -1
+}
+
+// quoteScanner finds the line closing a quoted value that spans lines, one line at a time so a long value
+// (or a quote that is never closed) is not scanned again for every line
+type quoteScanner struct {
+	quote   byte
+	escaped bool
+}
+
+// newQuoteScanner starts at the value opening the quote, see opensQuote
+func newQuoteScanner(val string) *quoteScanner {
+	val = strings.TrimLeft(val, " \t")
+
+	q := &quoteScanner{quote: val[0]}
+	q.scan(val[1:])
+
+	return q
+}
+
+// closes reports if the next line of the value closes the quote
+func (q *quoteScanner) closes(line string) bool {
+	// the line break before the line is part of the value, a backslash before it escapes it
+	q.escaped = false
+
+	return q.scan(line)
+}
+
+func (q *quoteScanner) scan(s string) bool {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case q.escaped:
+			q.escaped = false
+		case s[i] == '\\' && q.quote == '"':
+			q.escaped = true
+		case s[i] == q.quote:
+			return true
+		}
+	}
+
+	return false
+}
+
+// opensQuote reports if the value starts with a quote that is not closed on the same line
+func opensQuote(val string) bool {
+	val = strings.TrimLeft(val, " \t")
+
+	return val != "" && (val[0] == '"' || val[0] == '\'') && closingQuote(val) == -1
+}
+
+// unescape replaces the escape sequences of a double quoted value the way DialectDefault does
+func unescape(s string) string {
+	return unescapeOnly(s, DialectDefault.Escapes)
+}
+
+// unescapeOnly replaces the escapes of the chars in a double quoted value, other backslashes are kept
+// so \$ still escapes a dollar sign when expanding
+func unescapeOnly(s, chars string) string {
+	if !strings.ContainsRune(s, '\\') || chars == "" {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(chars, s[i+1]) != -1 {
+			i++
+			b.WriteString(control(s[i]))
+			continue
+		}
+
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
+
+// control returns the control char an escaped n, r or t stands for, any other char stands for itself
+func control(c byte) string {
+	switch c {
+	case 'n':
+		return "\n"
+	case 'r':
+		return "\r"
+	case 't':
+		return "\t"
+	}
+
+	return string(c)
+}
+
+// unescapeAny replaces the escapes of a double quoted value with BackslashEscapes, any char can be escaped.
+// literal is true if the value had a \$, so the dollar sign is kept when expanding
+func unescapeAny(s string) (val string, literal bool) {
+	if !strings.ContainsRune(s, '\\') {
+		return s, false
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case '$':
+			literal = true
+			fallthrough
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+
+	return b.String(), literal
+}
+
+// assign sets the key in the map according to the operator of the line, it returns false if the key was left as is
+func (p *parser) assign(emap *Map, key, op, val string) bool {
+	switch op {
+	case "?=":
+		if _, ok := lookup(emap, key); ok {
+			return false
+		}
+	case "+=":
+		if prev, ok := lookup(emap, key); ok && prev != "" {
+			val = prev + p.appendSep + val
+		}
+	}
+
+	emap.Set(key, val)
+	return true
+}
+
+// lookup finds the value of the key in the map, falling back to the env
+func lookup(emap *Map, key string) (string, bool) {
+	if val, ok := emap.Map[key]; ok {
+		return val, true
+	}
+
+	return os.LookupEnv(key)
+}
+
+// parseTags parses a `@tag:a,b` comment
+func parseTags(text string) []string {
+	var tags []string
+
+	for _, tag := range strings.Split(strings.TrimPrefix(text, "@tag:"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// continues reports if the line ends with a backslash that is not itself escaped
+func continues(line string) bool {
+	n := 0
+	for n < len(line) && line[len(line)-1-n] == '\\' {
+		n++
+	}
+
+	return n%2 == 1
+}
+
+// trimExport removes a leading export keyword
+func trimExport(s string) string {
+	if strings.HasPrefix(s, "export ") || strings.HasPrefix(s, "export\t") {
+		return s[len("export "):]
+	}
+
+	return s
+}
+
+// parseLine splits a line into its key, operator and value, ok is false if the line has no =
+func parseLine(line string, operators bool) (key, op, val string, ok bool) {
+	i := strings.IndexByte(line, '=')
+	if i == -1 {
+		return "", "", "", false
+	}
+
+	key, val = line[:i], line[i+1:]
+	op = "="
+
+	// files written to be sourced by a shell export their keys
+	key = strings.TrimLeft(trimExport(key), " ")
+
+	if n := len(key); operators && n != 0 {
+		switch key[n-1] {
+		case '?', '+', ':':
+			op = key[n-1:] + "="
+			key = key[:n-1]
+		}
+	}
+
+	// lines read like makefiles, so allow space around the = of any operator
+	return strings.TrimRight(key, " "), op, strings.TrimLeft(val, " "), true
+}
@@ -0,0 +1,170 @@
+package env
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    EnvMap
+	}{
+		{"plain", "A=1", EnvMap{"A": "1"}},
+		{"spaces around =", "A = b", EnvMap{"A": "b"}},
+		{"spaces around ?=", "A ?= b", EnvMap{"A": "b"}},
+		{"export", "export A=1", EnvMap{"A": "1"}},
+		{"comments and blank lines", "# a comment\nA=1\n\nB=2", EnvMap{"A": "1", "B": "2"}},
+		{"inline comment", "A=x # comment", EnvMap{"A": "x"}},
+		{"hash without space", "A=x#y", EnvMap{"A": "x#y"}},
+		{"single quotes", "A='x y'", EnvMap{"A": "x y"}},
+		{"single quotes are literal", `A='x\ny'`, EnvMap{"A": `x\ny`}},
+		{"double quotes escape", `A="x\ny"`, EnvMap{"A": "x\ny"}},
+		{"escaped quote", `A="q\"q"`, EnvMap{"A": `q"q`}},
+		{"multiline quote", "A=\"a\nb\"", EnvMap{"A": "a\nb"}},
+		{"heredoc", "A=<<EOF\nl1\nl2\nEOF", EnvMap{"A": "l1\nl2"}},
+		{"last one wins", "A=1\nA=2", EnvMap{"A": "2"}},
+		{"references are kept", "A=${B}", EnvMap{"A": "${B}"}},
+		{"line without =", "A\nB=2", EnvMap{"B": "2"}},
+		{"padded base64", "TOKEN=YWJj==", EnvMap{"TOKEN": "YWJj=="}},
+		{"padded base64 and a comment", "TOKEN=YWJj== # comment", EnvMap{"TOKEN": "YWJj=="}},
+		{"url with a query", "URL=https://x/?a=1&b=2", EnvMap{"URL": "https://x/?a=1&b=2"}},
+		{"url with a query and a comment", "URL=https://x/?a=1&b=2 # comment", EnvMap{"URL": "https://x/?a=1&b=2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.content).Map
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWindows(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    EnvMap
+	}{
+		{"crlf line endings", "A=1\r\nB=2\r\n", EnvMap{"A": "1", "B": "2"}},
+		{"byte order mark", "\ufeffA=1", EnvMap{"A": "1"}},
+		{"both", "\ufeffA=1\r\nB=2\r\n", EnvMap{"A": "1", "B": "2"}},
+		{"crlf in a multiline quote", "A=\"a\r\nb\"\r\nB=2", EnvMap{"A": "a\nb", "B": "2"}},
+		{"crlf after a continuation", "A=a \\\r\nb\r\n", EnvMap{"A": "a b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.content).Map
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSplitsOnFirstEquals(t *testing.T) {
+	content := "TOKEN=YWJj==\nURL=https://x/?a=1&b=2\n"
+	want := EnvMap{"TOKEN": "YWJj==", "URL": "https://x/?a=1&b=2"}
+
+	// the values are the same whether or not the dialect strips inline comments
+	for name, d := range map[string]Dialect{"inline comments": DialectDefault, "no inline comments": DialectDocker} {
+		if got := d.Parse(content).Map; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: Parse(%q) = %q, want %q", name, content, got, want)
+		}
+	}
+}
+
+func TestParseStrict(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    EnvMap
+		err     string
+	}{
+		{"valid", "A=1\nB='2'", EnvMap{"A": "1", "B": "2"}, ""},
+		{"missing =", "A", EnvMap{}, "line 1: missing ="},
+		{"empty key", "=1", EnvMap{}, `line 1: invalid key ""`},
+		{"unterminated quote", "A=\"open\nB=2", EnvMap{"B": "2"}, "line 1: unterminated quote"},
+		{"yaml line", "A: 1", EnvMap{}, "line 1: missing ="},
+		{"invalid key", "1A=2\nA B=1", EnvMap{}, `line 1: invalid key "1A", line 2: invalid key "A B"`},
+		{"unterminated heredoc", "A=<<EOF\nB=2\n", EnvMap{"B": "2"}, "line 1: unterminated heredoc"},
+		{"export without =", "export A", EnvMap{}, "line 1: missing ="},
+		{"every line is reported", "A\nB=1\n=2\nC=\"x", EnvMap{"B": "1"}, `line 1: missing =, line 3: invalid key "", line 4: unterminated quote`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseStrict(tt.content)
+
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatalf("ParseStrict(%q) failed: %s", tt.content, err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Fatalf("ParseStrict(%q) error = %v, want it to contain %q", tt.content, err, tt.err)
+			}
+
+			if !reflect.DeepEqual(got.Map, tt.want) {
+				t.Errorf("ParseStrict(%q) = %q, want %q", tt.content, got.Map, tt.want)
+			}
+		})
+	}
+}
+
+// errReader fails after returning its content
+type errReader struct {
+	content string
+	err     error
+}
+
+func (r *errReader) Read(p []byte) (int, error) {
+	if r.content == "" {
+		return 0, r.err
+	}
+
+	n := copy(p, r.content)
+	r.content = r.content[n:]
+	return n, nil
+}
+
+func TestParseStrictLineErrors(t *testing.T) {
+	_, err := ParseStrict("A=1\n\n# comment\nB\nC=3\n=4")
+
+	var syntax *SyntaxError
+	if !errors.As(err, &syntax) {
+		t.Fatalf("ParseStrict error = %v, want a *SyntaxError", err)
+	}
+
+	// blank and comment lines count too, so the numbers are the ones an editor shows
+	want := []LineError{{Line: 4, Text: "B", Message: "missing ="}, {Line: 6, Text: "=4", Message: `invalid key ""`}}
+	if !reflect.DeepEqual(syntax.Errors, want) {
+		t.Errorf("ParseStrict errors = %+v, want %+v", syntax.Errors, want)
+	}
+}
+
+func TestParseReader(t *testing.T) {
+	// a reader that returns a byte at a time, like a slow pipe, parses the same as the whole content
+	got, err := ParseReader(iotest.OneByteReader(strings.NewReader("A=1\nB=\"multi\nline\"\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (EnvMap{"A": "1", "B": "multi\nline"}); !reflect.DeepEqual(got.Map, want) {
+		t.Errorf("ParseReader = %q, want %q", got.Map, want)
+	}
+
+	// the error of the reader is returned with the lines read before it
+	failed := errors.New("connection reset")
+	got, err = ParseReader(&errReader{content: "A=1\n", err: failed})
+	if err != failed {
+		t.Errorf("ParseReader error = %v, want %v", err, failed)
+	}
+	if want := (EnvMap{"A": "1"}); !reflect.DeepEqual(got.Map, want) {
+		t.Errorf("ParseReader = %q, want %q", got.Map, want)
+	}
+}
@@ -0,0 +1,122 @@
+package env
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+ParseProperties parses a Java .properties file into env vars. The dots in keys become underscores
+and keys are upper cased, so database.host=localhost sets DATABASE_HOST. It follows the rules of
+java.util.Properties: # and ! comments, = or : or a space between the key and value, a trailing
+backslash continues the line and \t, \n, \uXXXX and friends are unescaped
+*/
+func ParseProperties(data []byte) (*Map, error) {
+	return parseProperties(data, "_")
+}
+
+// parseProperties parses a .properties file, joining the parts of dotted keys with sep
+func parseProperties(data []byte, sep string) (*Map, error) {
+	content := strings.ReplaceAll(string(data), "\r\n", "\n")
+	lines := strings.Split(content, "\n")
+	emap := NewMap()
+
+	for i := 0; i < len(lines); i++ {
+		number := i + 1
+		line := strings.TrimLeft(lines[i], " \t\f")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+
+		for continues(line) && i+1 < len(lines) {
+			i++
+			line = line[:len(line)-1] + strings.TrimLeft(lines[i], " \t\f")
+		}
+		if continues(line) {
+			line = line[:len(line)-1]
+		}
+
+		rawKey, rawVal := splitProperty(line)
+
+		key, err := unescapeProperty(rawKey)
+		if err == nil {
+			key, err = nestedKey(strings.Split(key, "."), sep)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid properties on line %d: %s", number, err)
+		}
+
+		val, err := unescapeProperty(rawVal)
+		if err != nil {
+			return nil, fmt.Errorf("invalid properties on line %d: %s", number, err)
+		}
+
+		emap.Set(key, val)
+	}
+
+	return emap, nil
+}
+
+// splitProperty splits a line on the first =, : or space that is not escaped
+func splitProperty(line string) (string, string) {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '=', ':':
+			return line[:i], strings.TrimLeft(line[i+1:], " \t\f")
+		case ' ', '\t', '\f':
+			// the key can be followed by space and then the = or :
+			rest := strings.TrimLeft(line[i:], " \t\f")
+			if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+				rest = strings.TrimLeft(rest[1:], " \t\f")
+			}
+			return line[:i], rest
+		}
+	}
+
+	return line, ""
+}
+
+// unescapeProperty replaces the escapes of a key or value, a backslash before any other character is dropped
+func unescapeProperty(s string) (string, error) {
+	if !strings.ContainsRune(s, '\\') {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch c := s[i]; c {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				return "", fmt.Errorf("invalid escape \\%s", s[i:])
+			}
+
+			r, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("invalid escape \\%s", s[i:i+5])
+			}
+			b.WriteRune(rune(r))
+			i += 4
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,89 @@
+package env
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PublicPrefixes are the prefixes frontend build tools expose to the browser, Vite, Next.js, Create React App,
+// Nuxt, Expo, Gatsby and SvelteKit
+var PublicPrefixes = []string{"VITE_", "NEXT_PUBLIC_", "REACT_APP_", "NUXT_PUBLIC_", "EXPO_PUBLIC_", "GATSBY_", "PUBLIC_"}
+
+// Public returns a new map with only the keys starting with one of the prefixes, PublicPrefixes if none are given,
+// so the client side of a full stack app can be built from the same env files as the server
+func (e *Map) Public(prefixes ...string) *Map {
+	if len(prefixes) == 0 {
+		prefixes = PublicPrefixes
+	}
+
+	return e.filter(func(key string) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+// OutputFormat is a format WriteFormat can write a map in
+type OutputFormat string
+
+const (
+	// OutputEnv is an env file, see Map.WriteTo
+	OutputEnv OutputFormat = "env"
+	// OutputJSON is a JSON object of the keys and values
+	OutputJSON OutputFormat = "json"
+	// OutputModule is a JavaScript module exporting the keys and values as its default export
+	OutputModule OutputFormat = "js"
+	// OutputDefine is a JSON object for the define option of Vite, esbuild and webpack's DefinePlugin, with
+	// process.env.KEY and import.meta.env.KEY replaced by the value
+	OutputDefine OutputFormat = "define"
+)
+
+// WriteFormat writes the keys and values of the map to w in the format, sorted by key or for OutputEnv in the order they were set
+func (e *Map) WriteFormat(w io.Writer, format OutputFormat) error {
+	values := make(map[string]string, len(e.Map))
+	for key, val := range e.Map {
+		values[key] = val
+	}
+
+	var out interface{} = values
+	switch format {
+	case OutputEnv:
+		_, err := e.WriteTo(w)
+		return err
+	case OutputJSON:
+	case OutputModule:
+		data, err := json.MarshalIndent(values, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(w, "export default %s;\n", data)
+		return err
+	case OutputDefine:
+		defines := make(map[string]string, 2*len(values))
+		for key, val := range values {
+			// the values of defines are code, so strings go in quotes
+			quoted, err := json.Marshal(val)
+			if err != nil {
+				return err
+			}
+
+			defines["process.env."+key] = string(quoted)
+			defines["import.meta.env."+key] = string(quoted)
+		}
+		out = defines
+	default:
+		return fmt.Errorf("unknown output format %q, expected env, json, js or define", format)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(out)
+}
@@ -0,0 +1,144 @@
+package env
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var (
+	// ErrPushCancelled is returned by Push when confirm turned the changes down
+	ErrPushCancelled = errors.New("push was cancelled")
+
+	// ErrNoPush is returned by Push for an adapter that has no Push function
+	ErrNoPush = errors.New("adapter can not push")
+)
+
+// Change is a key Push would change, Old is empty for a key that is not set remotely yet
+type Change struct {
+	Key string
+	Old string
+	New string
+
+	// Added is set for a key that is not set remotely
+	Added bool
+
+	// Secret values are masked by String
+	Secret bool
+}
+
+// String is the change as a line of a diff, with secret values replaced by RedactedValue
+func (c Change) String() string {
+	before, after := c.Old, c.New
+	if c.Secret {
+		before, after = RedactedValue, RedactedValue
+	}
+
+	if c.Added {
+		return fmt.Sprintf("+ %s=%s", c.Key, after)
+	}
+
+	return fmt.Sprintf("~ %s=%s -> %s", c.Key, before, after)
+}
+
+// Diff returns the keys of to that are not set in from or set to something else, sorted by key. A key is
+// secret if it is a secret in either map, see Map.IsSecret
+func Diff(from, to *Map) []Change {
+	var changes []Change
+
+	for _, key := range to.Keys() {
+		val := to.Map[key]
+		old, ok := from.Map[key]
+		if ok && old == val {
+			continue
+		}
+
+		changes = append(changes, Change{Key: key, Old: old, New: val, Added: !ok, Secret: to.IsSecret(key) || from.IsSecret(key)})
+	}
+
+	return changes
+}
+
+/*
+Push sets the keys of emap in the place the named adapter pulls from, with the adapter's Push
+function. The adapter is pulled first so only the keys that would change are pushed, and confirm
+(when it is not nil) sees them before anything is written. If confirm returns false nothing is pushed
+and ErrPushCancelled is returned, ConfirmPrompt asks on a terminal
+
+	err := loader.Push("vault", emap, env.ConfirmPrompt(os.Stdin, os.Stdout))
+*/
+func (l *Loader) Push(adapter string, emap *Map, confirm func(changes []Change) bool) error {
+	a := l.adapter(adapter)
+	if a == nil {
+		return fmt.Errorf("there is no adapter named %q", adapter)
+	}
+	if a.Push == nil {
+		return &AdapterError{Adapter: adapter, Err: ErrNoPush}
+	}
+	if a.Network && l.config().offline {
+		return &AdapterError{Adapter: adapter, Err: ErrOffline}
+	}
+
+	remote, err := a.pull(Environ())
+	if err != nil {
+		return &AdapterError{Adapter: adapter, Err: err}
+	}
+
+	changes := Diff(remote, emap)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	if confirm != nil && !confirm(changes) {
+		return ErrPushCancelled
+	}
+
+	changed := NewMap()
+	for _, c := range changes {
+		changed.Set(c.Key, c.New)
+		changed.copyInfo(emap, c.Key)
+	}
+
+	err = a.push(changed)
+	if err != nil {
+		return &AdapterError{Adapter: adapter, Err: err}
+	}
+
+	return nil
+}
+
+// adapter returns the adapter with the name, adapters without one go by "adapter <n>" like in errors
+func (l *Loader) adapter(name string) *Adapter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var found *Adapter
+	for i, a := range l.adapters {
+		n := a.Name
+		if n == "" {
+			n = fmt.Sprintf("adapter %d", i+1)
+		}
+		if n == name {
+			found = a
+		}
+	}
+
+	return found
+}
+
+// ConfirmPrompt returns a confirm function for Push that writes the masked diff to out and asks for a yes on in
+func ConfirmPrompt(in io.Reader, out io.Writer) func(changes []Change) bool {
+	return func(changes []Change) bool {
+		for _, c := range changes {
+			fmt.Fprintln(out, c)
+		}
+		fmt.Fprintf(out, "push %d changes? [y/N] ", len(changes))
+
+		answer, _ := bufio.NewReader(in).ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+
+		return answer == "y" || answer == "yes"
+	}
+}
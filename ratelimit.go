@@ -0,0 +1,66 @@
+package env
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+/*
+RateLimit returns an adapter that pulls with a at most once every interval, a pull that comes sooner
+gets the keys of the last one. Many services refreshing against the same backend, like a Server or
+a loop around Load in every replica, then can not hit it more often than that however often they read
+
+	env.ApplyAdapter(env.RateLimit(vault, time.Minute))
+
+Failed pulls are not remembered, the next pull tries again
+*/
+func RateLimit(a *Adapter, interval time.Duration) *Adapter {
+	var mu sync.Mutex
+	var last *Map
+	var pulled time.Time
+
+	limited := *a
+	limited.PullFrom = func(loaded Reader) (*Map, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if last == nil || time.Since(pulled) >= interval {
+			emap, err := a.pull(loaded)
+			if err != nil {
+				return nil, err
+			}
+
+			last, pulled = emap, time.Now()
+		}
+
+		// the loader sets sources and runs hooks on what Pull returns, so it gets a copy
+		emap := NewMap()
+		emap.SetMap(last)
+
+		return emap, nil
+	}
+	limited.Pull = func() (*Map, error) {
+		return limited.PullFrom(Environ())
+	}
+
+	return &limited
+}
+
+var (
+	// jitterRand is seeded per process so services started by the same deploy do not pick the same delays
+	jitterMu   sync.Mutex
+	jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// jitter returns a random duration from 0 up to max
+func jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+
+	jitterMu.Lock()
+	defer jitterMu.Unlock()
+
+	return time.Duration(jitterRand.Int63n(int64(max)))
+}
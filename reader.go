@@ -0,0 +1,185 @@
+package env
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// Reader reads env vars. Hand a plugin or third party module a Reader from Scope instead of the
+// whole env so it can only see the keys meant for it
+type Reader interface {
+	// Lookup returns the value of the key and if it is set
+	Lookup(key string) (string, bool)
+
+	// Keys returns the sorted keys the Reader can see
+	Keys() []string
+}
+
+// Environ returns a Reader for the env of the process
+func Environ() Reader {
+	return environ{}
+}
+
+type environ struct{}
+
+func (environ) Lookup(key string) (string, bool) {
+	return os.LookupEnv(key)
+}
+
+func (environ) Keys() []string {
+	var keys []string
+	for _, kv := range os.Environ() {
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			keys = append(keys, kv[:i])
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// getenv is os.Getenv on a Reader
+func getenv(r Reader, key string) string {
+	val, _ := r.Lookup(key)
+	return val
+}
+
+// Lookup returns the value of the key and if it is in the map, so a Map is a Reader
+func (e *Map) Lookup(key string) (string, bool) {
+	val, ok := e.Map[key]
+	return val, ok
+}
+
+// Keys returns the sorted keys of the map
+func (e *Map) Keys() []string {
+	var keys []string
+	for key := range e.Map {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Layered returns a Reader that looks keys up in each Reader in turn, so the first one wins
+func Layered(readers ...Reader) Reader {
+	return layered(readers)
+}
+
+type layered []Reader
+
+func (l layered) Lookup(key string) (string, bool) {
+	for _, r := range l {
+		if val, ok := r.Lookup(key); ok {
+			return val, true
+		}
+	}
+
+	return "", false
+}
+
+func (l layered) Keys() []string {
+	seen := map[string]bool{}
+
+	var keys []string
+	for _, r := range l {
+		for _, key := range r.Keys() {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Scope returns a Reader of the env that only sees keys starting with one of the prefixes
+func Scope(prefixes ...string) Reader {
+	return ScopeOf(Environ(), prefixes...)
+}
+
+// ScopeOf returns a Reader that only sees the keys of r starting with one of the prefixes
+func ScopeOf(r Reader, prefixes ...string) Reader {
+	return &scoped{r: r, prefixes: prefixes}
+}
+
+type scoped struct {
+	r        Reader
+	prefixes []string
+}
+
+func (s *scoped) allowed(key string) bool {
+	for _, prefix := range s.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *scoped) Lookup(key string) (string, bool) {
+	if !s.allowed(key) {
+		return "", false
+	}
+
+	return s.r.Lookup(key)
+}
+
+func (s *scoped) Keys() []string {
+	var keys []string
+	for _, key := range s.r.Keys() {
+		if s.allowed(key) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+/*
+ForTenant returns a Reader of the env that resolves keys for a tenant first, so a single binary can
+serve many tenants from one env. With the tenant T123 looking up DB_URL returns T123_DB_URL if it
+is set, and DB_URL otherwise
+*/
+func ForTenant(id string) Reader {
+	return TenantOf(Environ(), id)
+}
+
+// TenantOf is ForTenant on a Reader
+func TenantOf(r Reader, id string) Reader {
+	return &tenant{r: r, prefix: strings.ToUpper(id) + "_"}
+}
+
+type tenant struct {
+	r      Reader
+	prefix string
+}
+
+func (t *tenant) Lookup(key string) (string, bool) {
+	if val, ok := t.r.Lookup(t.prefix + key); ok {
+		return val, true
+	}
+
+	return t.r.Lookup(key)
+}
+
+// Keys returns the keys of the Reader, with the keys of the tenant under their name without the prefix
+func (t *tenant) Keys() []string {
+	seen := map[string]bool{}
+
+	var keys []string
+	for _, key := range t.r.Keys() {
+		key = strings.TrimPrefix(key, t.prefix)
+		if key != "" && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
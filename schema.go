@@ -0,0 +1,512 @@
+package env
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SchemaVersion is the version of the schema format this package reads, see MigrateSchema to upgrade older schemas
+const SchemaVersion = 1
+
+/*
+Schema describes the keys an app expects, written as a JSON file that is checked in next to the env files
+
+	{
+	  "version": 1,
+	  "keys": {
+	    "PORT": {"type": "int", "required": true, "description": "port the http server listens on"},
+	    "LOG_LEVEL": {"default": "info", "default@production": "warn"}
+	  }
+	}
+
+A `default@<environment>` is used instead of the default when APP_ENV is that environment.
+The version is the version of the format itself, a schema in another version than SchemaVersion
+is refused instead of being validated against half understood rules
+*/
+type Schema struct {
+	Version int                   `json:"version"`
+	Keys    map[string]*KeySchema `json:"keys"`
+}
+
+// KeySchema describes a single key of a Schema
+type KeySchema struct {
+	// Type is one of string (the default), int, float, bool, duration, url, cron, regexp or glob
+	Type     string `json:"type,omitempty"`
+	Required bool   `json:"required,omitempty"`
+
+	// Default is used by Defaults when the key is not set
+	Default     string `json:"default,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	// Deprecated marks a key that is going away with what to use instead, like "use DATABASE_URL".
+	// Setting it is warned about until RemovedAfter, a date like 2026-06-30, and fails Validate after it
+	Deprecated   string `json:"deprecated,omitempty"`
+	RemovedAfter string `json:"removed_after,omitempty"`
+
+	// Owner and Link say who to contact about the key and where its runbook is, they are added to its problems
+	Owner string `json:"owner,omitempty"`
+	Link  string `json:"link,omitempty"`
+
+	// Tags group the key like a `# @tag:` comment in an env file, RequiredTagged requires it even when no file sets it
+	Tags []string `json:"tags,omitempty"`
+
+	// EnvironmentDefaults are the default@<environment> defaults, by environment
+	EnvironmentDefaults map[string]string `json:"-"`
+}
+
+// keySchema is KeySchema without its methods, for the JSON encoding
+type keySchema KeySchema
+
+// UnmarshalJSON reads the default@<environment> fields into EnvironmentDefaults
+func (ks *KeySchema) UnmarshalJSON(data []byte) error {
+	err := json.Unmarshal(data, (*keySchema)(ks))
+	if err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return err
+	}
+
+	for name, raw := range fields {
+		if !strings.HasPrefix(name, "default@") {
+			continue
+		}
+
+		var val string
+		err = json.Unmarshal(raw, &val)
+		if err != nil {
+			return fmt.Errorf("%s must be a string", name)
+		}
+
+		if ks.EnvironmentDefaults == nil {
+			ks.EnvironmentDefaults = map[string]string{}
+		}
+		ks.EnvironmentDefaults[strings.TrimPrefix(name, "default@")] = val
+	}
+
+	return nil
+}
+
+// MarshalJSON writes EnvironmentDefaults back as default@<environment> fields
+func (ks KeySchema) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(keySchema(ks))
+	if err != nil || len(ks.EnvironmentDefaults) == 0 {
+		return data, err
+	}
+
+	var fields map[string]interface{}
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	for environment, val := range ks.EnvironmentDefaults {
+		fields["default@"+environment] = val
+	}
+
+	return json.Marshal(fields)
+}
+
+// DefaultFor returns the default of the key in the environment, falling back to Default
+func (ks *KeySchema) DefaultFor(environment string) string {
+	if val, ok := ks.EnvironmentDefaults[environment]; ok && environment != "" {
+		return val
+	}
+
+	return ks.Default
+}
+
+// problem is a Problem with the key, carrying its owner and link
+func (ks *KeySchema) problem(key, message string) Problem {
+	return Problem{Key: key, Message: message, Owner: ks.Owner, Link: ks.Link}
+}
+
+// removal returns when the deprecated key stops being accepted, which is the end of the RemovedAfter day
+func (ks *KeySchema) removal() (time.Time, error) {
+	if ks.RemovedAfter == "" {
+		return time.Time{}, nil
+	}
+
+	day, err := time.Parse("2006-01-02", ks.RemovedAfter)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return day.AddDate(0, 0, 1), nil
+}
+
+// removed reports if the key is deprecated and past its removal date
+func (ks *KeySchema) removed(now time.Time) bool {
+	removal, _ := ks.removal()
+
+	return ks.Deprecated != "" && !removal.IsZero() && !now.Before(removal)
+}
+
+// schemaEnvironment returns the current environment, envKey (APP_ENV unless WithEnvironmentKey changed it) in r or else in the env
+func schemaEnvironment(r Reader, envKey string) string {
+	if environment, ok := r.Lookup(envKey); ok {
+		return environment
+	}
+
+	return os.Getenv(envKey)
+}
+
+// SchemaVersionError is returned when a schema is in a version this package can not read
+type SchemaVersionError struct {
+	Version   int
+	Supported int
+}
+
+func (e *SchemaVersionError) Error() string {
+	if e.Version < e.Supported {
+		return fmt.Sprintf("schema version %d is older than the supported version %d, upgrade it with MigrateSchema or `envctl schema migrate`", e.Version, e.Supported)
+	}
+
+	return fmt.Sprintf("schema version %d is newer than the supported version %d, update env", e.Version, e.Supported)
+}
+
+// SchemaError is returned by Validate with every key that does not match the schema
+type SchemaError struct {
+	Problems []Problem
+}
+
+// Problem is a key that does not match the schema, with the owner and link of the key if the schema has them
+type Problem struct {
+	Key     string `json:"key"`
+	Message string `json:"message"`
+	Owner   string `json:"owner,omitempty"`
+	Link    string `json:"link,omitempty"`
+}
+
+func (p Problem) String() string {
+	s := p.Key + " " + p.Message
+
+	var contact []string
+	if p.Owner != "" {
+		contact = append(contact, "contact "+p.Owner)
+	}
+	if p.Link != "" {
+		contact = append(contact, "see "+p.Link)
+	}
+	if len(contact) != 0 {
+		s += " (" + strings.Join(contact, " / ") + ")"
+	}
+
+	return s
+}
+
+func (e *SchemaError) Error() string {
+	var problems []string
+	for _, p := range e.Problems {
+		problems = append(problems, p.String())
+	}
+
+	return "env does not match the schema: " + strings.Join(problems, ", ")
+}
+
+// LoadSchema reads and parses the schema file
+func LoadSchema(path string) (*Schema, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read schema: %s", err)
+	}
+
+	s, err := ParseSchema(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not load schema %s: %w", path, err)
+	}
+
+	return s, nil
+}
+
+// ParseSchema parses a schema, a schema in another version than SchemaVersion is a *SchemaVersionError
+func ParseSchema(data []byte) (*Schema, error) {
+	var s Schema
+
+	version, err := schemaVersion(data)
+	if err != nil {
+		return nil, err
+	}
+	if version != SchemaVersion {
+		return nil, &SchemaVersionError{Version: version, Supported: SchemaVersion}
+	}
+
+	err = json.Unmarshal(data, &s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %s", err)
+	}
+
+	for key, ks := range s.Keys {
+		if ks == nil {
+			s.Keys[key] = &KeySchema{}
+			continue
+		}
+
+		if _, ok := typeCheckers[ks.Type]; !ok && ks.Type != "" {
+			return nil, fmt.Errorf("invalid schema: %s has unknown type %q", key, ks.Type)
+		}
+
+		if _, err := ks.removal(); err != nil {
+			return nil, fmt.Errorf("invalid schema: %s has removed_after %q, expected a date like 2006-01-02", key, ks.RemovedAfter)
+		}
+	}
+
+	return &s, nil
+}
+
+// schemaVersion reads the version of a schema, a schema without one is version 0
+func schemaVersion(data []byte) (int, error) {
+	var header struct {
+		Version int `json:"version"`
+	}
+
+	err := json.Unmarshal(data, &header)
+	if err != nil {
+		return 0, fmt.Errorf("invalid schema: %s", err)
+	}
+
+	return header.Version, nil
+}
+
+// schemaMigrations upgrade a schema from the version they are stored under to the next version
+var schemaMigrations = map[int]func(doc map[string]json.RawMessage) (map[string]json.RawMessage, error){
+	// version 0 had no version and the keys at the top level
+	0: func(doc map[string]json.RawMessage) (map[string]json.RawMessage, error) {
+		keys, err := json.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]json.RawMessage{"keys": keys}, nil
+	},
+}
+
+/*
+MigrateSchema upgrades a schema in an older version to SchemaVersion, one version at a time, and
+returns the new file. A schema that is already in SchemaVersion is returned as it is
+*/
+func MigrateSchema(data []byte) ([]byte, error) {
+	version, err := schemaVersion(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if version == SchemaVersion {
+		return data, nil
+	}
+	if version > SchemaVersion {
+		return nil, &SchemaVersionError{Version: version, Supported: SchemaVersion}
+	}
+
+	var doc map[string]json.RawMessage
+	err = json.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %s", err)
+	}
+	delete(doc, "version")
+
+	for ; version < SchemaVersion; version++ {
+		migrate, ok := schemaMigrations[version]
+		if !ok {
+			return nil, fmt.Errorf("no migration from schema version %d", version)
+		}
+
+		doc, err = migrate(doc)
+		if err != nil {
+			return nil, fmt.Errorf("could not migrate schema from version %d: %s", version, err)
+		}
+	}
+
+	doc["version"] = json.RawMessage(strconv.Itoa(SchemaVersion))
+
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(out, '\n'), nil
+}
+
+// MigrateSchemaFile upgrades the schema file at path like MigrateSchema, the new file is renamed over the
+// old one so nothing ever reads half of it and a failed write leaves the old schema in place
+func MigrateSchemaFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	migrated, err := MigrateSchema(data)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, migrated, info.Mode().Perm())
+}
+
+// typeCheckers check a value is of a schema type
+var typeCheckers = map[string]func(val string) error{
+	"string": func(string) error { return nil },
+	"int": func(val string) error {
+		_, err := strconv.Atoi(val)
+		return err
+	},
+	"float": func(val string) error {
+		_, err := strconv.ParseFloat(val, 64)
+		return err
+	},
+	"bool": func(val string) error {
+		_, err := strconv.ParseBool(val)
+		return err
+	},
+	"duration": func(val string) error {
+		_, err := time.ParseDuration(val)
+		return err
+	},
+	"url": func(val string) error {
+		u, err := url.Parse(val)
+		if err == nil && u.Scheme == "" {
+			err = fmt.Errorf("missing scheme")
+		}
+		return err
+	},
+	"cron": checkCron,
+	"regexp": func(val string) error {
+		_, err := regexp.Compile(val)
+		return err
+	},
+	"glob": func(val string) error {
+		_, err := path.Match(val, "")
+		return err
+	},
+}
+
+// Validate checks the keys of r against the schema, every problem is listed in a *SchemaError
+func (s *Schema) Validate(r Reader) error {
+	return s.validate(r, "APP_ENV")
+}
+
+func (s *Schema) validate(r Reader, envKey string) error {
+	var problems []Problem
+	keys := s.sortedKeys()
+
+	environment := schemaEnvironment(r, envKey)
+	now := time.Now()
+
+	for _, key := range keys {
+		ks := s.Keys[key]
+
+		// a default only fills in a key that is not set at all, see Defaults
+		val, ok := r.Lookup(key)
+		if !ok || val == "" {
+			if ks.Required && (ok || ks.DefaultFor(environment) == "") {
+				problems = append(problems, ks.problem(key, "is required"))
+			}
+			continue
+		}
+
+		if ks.removed(now) {
+			problems = append(problems, ks.problem(key, fmt.Sprintf("was removed after %s: %s", ks.RemovedAfter, ks.Deprecated)))
+			continue
+		}
+
+		if check, ok := typeCheckers[ks.Type]; ok {
+			if err := check(val); err != nil {
+				problems = append(problems, ks.problem(key, fmt.Sprintf("is not a valid %s", ks.Type)))
+			}
+		}
+	}
+
+	if len(problems) != 0 {
+		return &SchemaError{Problems: problems}
+	}
+
+	return nil
+}
+
+// Deprecations returns the deprecated keys that are set in r but not removed yet, to warn about them
+func (s *Schema) Deprecations(r Reader) []Problem {
+	var warnings []Problem
+	now := time.Now()
+
+	for _, key := range s.sortedKeys() {
+		ks := s.Keys[key]
+		if _, ok := r.Lookup(key); !ok || ks.Deprecated == "" || ks.removed(now) {
+			continue
+		}
+
+		message := "is deprecated: " + ks.Deprecated
+		if ks.RemovedAfter != "" {
+			message = fmt.Sprintf("is deprecated and will be removed after %s: %s", ks.RemovedAfter, ks.Deprecated)
+		}
+		warnings = append(warnings, ks.problem(key, message))
+	}
+
+	return warnings
+}
+
+// sortedKeys returns the keys of the schema in order, so problems are listed the same way every time
+func (s *Schema) sortedKeys() []string {
+	var keys []string
+	for key := range s.Keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Tagged returns the keys of the schema that have the tag, sorted
+func (s *Schema) Tagged(tag string) []string {
+	var keys []string
+	for _, key := range s.sortedKeys() {
+		if hasString(s.Keys[key].Tags, tag) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// Defaults returns the defaults of the keys that are not set in r, for the environment in APP_ENV
+func (s *Schema) Defaults(r Reader) *Map {
+	return s.defaults(r, "APP_ENV")
+}
+
+func (s *Schema) defaults(r Reader, envKey string) *Map {
+	emap := NewMap()
+	environment := schemaEnvironment(r, envKey)
+
+	// in order so the defaults are listed the same way every time
+	for _, key := range s.sortedKeys() {
+		ks := s.Keys[key]
+		def := ks.DefaultFor(environment)
+		if _, ok := r.Lookup(key); ok || def == "" {
+			continue
+		}
+
+		emap.Set(key, def)
+		emap.SetSource(key, "schema")
+		if ks.Description != "" {
+			emap.SetDescription(key, ks.Description)
+		}
+	}
+
+	return emap
+}
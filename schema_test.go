@@ -0,0 +1,64 @@
+package env
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestMigrateSchemaFile(t *testing.T) {
+	path := filepath.Join(filepath.Dir(writeEnvFile(t, "")), "schema.json")
+	err := ioutil.WriteFile(path, []byte(`{"PORT": {"type": "int", "required": true}}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = MigrateSchemaFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version, err := schemaVersion(data); err != nil || version != SchemaVersion {
+		t.Errorf("the file is in version %d (%v), want %d:\n%s", version, err, SchemaVersion, data)
+	}
+
+	// the file keeps its permissions and the temp file it was written to is gone
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("the file mode is %v, want 0600", info.Mode().Perm())
+	}
+
+	entries, err := ioutil.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name() != ".env" && e.Name() != "schema.json" {
+			t.Errorf("%s was left next to the schema", e.Name())
+		}
+	}
+}
+
+func TestSchemaDefaultsOrder(t *testing.T) {
+	s, err := ParseSchema([]byte(`{"version": 1, "keys": {"E": {"default": "5"}, "B": {"default": "2"}, "D": {"default": "4"}, "A": {"default": "1"}, "C": {"default": "3"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the map keeps the order the defaults are set in, which is the same every time
+	for i := 0; i < 10; i++ {
+		if got := s.Defaults(NewMap()).Ordered(); !reflect.DeepEqual(got, []string{"A", "B", "C", "D", "E"}) {
+			t.Fatalf("the defaults are set in the order %q, want them sorted", got)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package env
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AccessEvent is passed to the audit hook of a SecureStore every time a value is read
+type AccessEvent struct {
+	Key   string
+	Found bool
+	Time  time.Time
+	// Caller is the file:line that read the value
+	Caller string
+}
+
+/*
+SecureStore holds values AES-GCM encrypted in memory with a random key that never leaves the store,
+they are only decrypted inside the getters and every read goes through the audit hook. Use
+Loader.LoadSecure to load into a store instead of the env.
+
+Values are still plain text for a moment while they are parsed and when a getter returns them,
+Use hands out the decrypted bytes and wipes them afterwards for the strictest handling
+*/
+type SecureStore struct {
+	mu     sync.RWMutex
+	aead   cipher.AEAD
+	values map[string][]byte
+	audit  func(AccessEvent)
+}
+
+// NewSecureStore creates an empty store, audit is called for every read and can be nil
+func NewSecureStore(audit func(AccessEvent)) (*SecureStore, error) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate a key for the secure store: %s", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	wipe(key)
+
+	return &SecureStore{
+		aead:   aead,
+		values: make(map[string][]byte),
+		audit:  audit,
+	}, nil
+}
+
+// Set encrypts the value and stores it
+func (s *SecureStore) Set(key, val string) error {
+	nonce := make([]byte, s.aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return err
+	}
+
+	// the key is the additional data so a value can not be swapped to another key
+	sealed := s.aead.Seal(nonce, nonce, []byte(val), []byte(key))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.values[key] = sealed
+	return nil
+}
+
+// SetMap stores every key of the map
+func (s *SecureStore) SetMap(m *Map) error {
+	for _, key := range m.Ordered() {
+		err := s.Set(key, m.Map[key])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Keys returns the sorted keys in the store, reading the keys is not audited
+func (s *SecureStore) Keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []string
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Use decrypts the value and passes it to fn, the bytes are wiped once fn returns so fn must not keep them
+func (s *SecureStore) Use(key string, fn func(val []byte)) (bool, error) {
+	val, ok, err := s.open(key, 2)
+	if err != nil || !ok {
+		return ok, err
+	}
+	defer wipe(val)
+
+	fn(val)
+	return true, nil
+}
+
+// Get returns the decrypted value of the key
+func (s *SecureStore) Get(key string) (string, bool, error) {
+	val, ok, err := s.open(key, 2)
+	if err != nil || !ok {
+		return "", ok, err
+	}
+	defer wipe(val)
+
+	return string(val), true, nil
+}
+
+// GetInt returns the value of the key as an int
+func (s *SecureStore) GetInt(key string) (int, error) {
+	val, err := s.get(key)
+	if err != nil {
+		return 0, err
+	}
+
+	i, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not an int", key)
+	}
+
+	return i, nil
+}
+
+// GetBool returns the value of the key as a bool
+func (s *SecureStore) GetBool(key string) (bool, error) {
+	val, err := s.get(key)
+	if err != nil {
+		return false, err
+	}
+
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, fmt.Errorf("%s is not a bool", key)
+	}
+
+	return b, nil
+}
+
+// GetDuration returns the value of the key as a time.Duration
+func (s *SecureStore) GetDuration(key string) (time.Duration, error) {
+	val, err := s.get(key)
+	if err != nil {
+		return 0, err
+	}
+
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a duration", key)
+	}
+
+	return d, nil
+}
+
+// get is Get for the typed getters, where a missing key is an error. The errors never include the value
+func (s *SecureStore) get(key string) (string, error) {
+	val, ok, err := s.open(key, 3)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("%s is not set", key)
+	}
+	defer wipe(val)
+
+	return string(val), nil
+}
+
+// open decrypts the value of the key and tells the audit hook about it, skip is how many
+// callers up the code reading the value is
+func (s *SecureStore) open(key string, skip int) ([]byte, bool, error) {
+	s.mu.RLock()
+	sealed, ok := s.values[key]
+	s.mu.RUnlock()
+
+	if s.audit != nil {
+		event := AccessEvent{Key: key, Found: ok, Time: time.Now()}
+
+		if _, file, line, ok := runtime.Caller(skip); ok {
+			event.Caller = fmt.Sprintf("%s:%d", file, line)
+		}
+
+		s.audit(event)
+	}
+
+	if !ok {
+		return nil, false, nil
+	}
+
+	size := s.aead.NonceSize()
+	val, err := s.aead.Open(nil, sealed[:size], sealed[size:], []byte(key))
+	if err != nil {
+		return nil, false, fmt.Errorf("could not decrypt %s: %s", key, err)
+	}
+
+	return val, true, nil
+}
+
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,318 @@
+package env
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxWait is how long a watching request to the Server waits for a change before it gets the current env again
+const maxWait = 30 * time.Second
+
+/*
+Server serves the env a Loader reads over HTTP on localhost or a unix socket, so services in other
+languages on the same host can use one config pipeline. It reads the env again every Interval and
+keeps serving the last good env if that fails.
+
+Every request needs the token as `Authorization: Bearer <token>`
+
+	GET /v1/env            the env as {"version": 1, "env": {...}, "sources": {...}}
+	GET /v1/env?wait=1     waits until the env is not version 1 anymore (or 30 seconds pass)
+	GET /v1/env/KEY        the value of a single key as text
+	GET /v1/health         the version, when it was last refreshed and the last refresh error
+*/
+type Server struct {
+	Loader *Loader
+	Token  string
+
+	// Interval is how often the env is read again, by default 30 seconds
+	Interval time.Duration
+
+	// Jitter adds a random wait of up to Jitter to every Interval, so servers started by the same
+	// deploy spread their reads out instead of hitting the adapters' backends at the same time
+	Jitter time.Duration
+
+	mu        sync.Mutex
+	env       *Map
+	version   int
+	refreshed time.Time
+	err       error
+
+	// changed is closed and replaced every time the env changes
+	changed chan struct{}
+}
+
+// NewServer creates a Server for the Loader, use ListenAndServe to start it
+func NewServer(l *Loader, token string) *Server {
+	return &Server{Loader: l, Token: token, changed: make(chan struct{})}
+}
+
+// Refresh reads the env again, the version only goes up when something changed
+func (s *Server) Refresh() error {
+	emap, err := s.Loader.Read()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshed = time.Now()
+	s.err = err
+	if err != nil {
+		return err
+	}
+
+	if s.env != nil && sameEnv(s.env, emap) {
+		return nil
+	}
+
+	s.env = emap
+	s.version++
+	if s.changed != nil {
+		close(s.changed)
+	}
+	s.changed = make(chan struct{})
+
+	return nil
+}
+
+// sameEnv reports if the maps have the same keys, values and sources
+func sameEnv(a, b *Map) bool {
+	if len(a.Map) != len(b.Map) {
+		return false
+	}
+
+	for key, val := range a.Map {
+		if other, ok := b.Map[key]; !ok || other != val || a.Source(key) != b.Source(key) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Run refreshes the env every Interval, plus up to Jitter, until ctx is done and then returns nil. A failed
+// refresh does not stop it, the Server keeps the last good env and reports the error on /v1/health
+func (s *Server) Run(ctx context.Context) error {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	timer := time.NewTimer(interval + jitter(s.Jitter))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-timer.C:
+			s.Refresh()
+			timer.Reset(interval + jitter(s.Jitter))
+		}
+	}
+}
+
+/*
+ListenAndServe reads the env, then serves it on addr until ctx is done. addr is either `unix:///path/to.sock`
+or a host:port on the loopback interface, like 127.0.0.1:7070, since the env should never leave the host.
+It returns once the server has shut down and its refreshes have stopped, nil when that was because of ctx.
+Requests still running after 5 seconds are cut off and the error of the shutdown is returned
+*/
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	if s.Token == "" {
+		return errors.New("the env server needs a token")
+	}
+
+	err := s.Refresh()
+	if err != nil {
+		return err
+	}
+
+	ln, err := listen(addr)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	srv := &http.Server{
+		Handler: s,
+
+		// Shutdown does not cancel requests, so they get ctx and long polls with ?wait= end with it
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	var wg sync.WaitGroup
+	var shutdownErr error
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		s.Run(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		shutdownErr = srv.Shutdown(shutdown)
+		if shutdownErr != nil {
+			// whatever did not finish in time is cut off
+			srv.Close()
+		}
+	}()
+
+	err = srv.Serve(ln)
+
+	// cancel, then wait for both before returning so nothing is left running once ListenAndServe is done
+	cancel()
+	wg.Wait()
+
+	if err != http.ErrServerClosed {
+		return err
+	}
+
+	return shutdownErr
+}
+
+// listen listens on a unix socket or a loopback address
+func listen(addr string) (net.Listener, error) {
+	if strings.HasPrefix(addr, "unix://") {
+		path := strings.TrimPrefix(addr, "unix://")
+
+		// a socket left behind by a server that did not shut down would fail the listen
+		if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(path)
+		}
+
+		ln, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, err
+		}
+
+		// only the user running the server can connect
+		err = os.Chmod(path, 0600)
+		if err != nil {
+			ln.Close()
+			return nil, err
+		}
+
+		return ln, nil
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("refusing to serve the env on %s, use a loopback address or a unix socket", addr)
+	}
+
+	return net.Listen("tcp", addr)
+}
+
+// serverResponse is the body of GET /v1/env
+type serverResponse struct {
+	Version int               `json:"version"`
+	Env     map[string]string `json:"env"`
+	Sources map[string]string `json:"sources"`
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if s.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/v1/env":
+		s.serveEnv(w, r)
+	case strings.HasPrefix(r.URL.Path, "/v1/env/"):
+		s.mu.Lock()
+		val, ok := "", false
+		if s.env != nil {
+			val, ok = s.env.Map[strings.TrimPrefix(r.URL.Path, "/v1/env/")]
+		}
+		s.mu.Unlock()
+
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(val))
+	case r.URL.Path == "/v1/health":
+		s.mu.Lock()
+		health := map[string]interface{}{"version": s.version, "refreshed": s.refreshed}
+		if s.err != nil {
+			health["error"] = s.err.Error()
+		}
+		s.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(health)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveEnv(w http.ResponseWriter, r *http.Request) {
+	if wait := r.URL.Query().Get("wait"); wait != "" {
+		version, err := strconv.Atoi(wait)
+		if err != nil {
+			http.Error(w, "wait must be a version", http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		current, changed := s.version, s.changed
+		s.mu.Unlock()
+
+		if current == version {
+			timer := time.NewTimer(maxWait)
+			defer timer.Stop()
+
+			select {
+			case <-changed:
+			case <-timer.C:
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+
+	s.mu.Lock()
+	resp := serverResponse{Version: s.version, Env: map[string]string{}, Sources: map[string]string{}}
+	if s.env != nil {
+		for key, val := range s.env.Map {
+			resp.Env[key] = val
+			if source := s.env.Source(key); source != "" {
+				resp.Sources[key] = source
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
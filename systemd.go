@@ -0,0 +1,73 @@
+package env
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+/*
+SystemdCredentials returns an adapter that exports the credentials systemd passed to the service with
+LoadCredential= or SetCredential=, which it puts as files in CREDENTIALS_DIRECTORY (looked up in what
+was loaded before the adapter, then the env).
+
+The name of a credential becomes its key, upper cased with anything that is not a letter or digit
+turned into a _ (db-password becomes DB_PASSWORD), and one trailing line break is trimmed from the value.
+Without names every credential is exported, with names only those are and a missing one is an error.
+When the service is not started with credentials the adapter returns nothing
+*/
+func SystemdCredentials(names ...string) *Adapter {
+	return loadedAdapter("systemd-credentials", false, func(loaded Reader) (*Map, error) {
+		emap := NewMap()
+
+		dir := getenv(loaded, "CREDENTIALS_DIRECTORY")
+		if dir == "" {
+			if len(names) != 0 {
+				return nil, fmt.Errorf("systemd credentials %s not found: CREDENTIALS_DIRECTORY is not set", names)
+			}
+			return emap, nil
+		}
+
+		// without names the directory is listed on every pull, so credentials added since are picked up
+		creds := names
+		if len(creds) == 0 {
+			files, err := ioutil.ReadDir(dir)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, f := range files {
+				if !f.IsDir() {
+					creds = append(creds, f.Name())
+				}
+			}
+		}
+
+		for _, name := range creds {
+			bytes, err := ioutil.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				return nil, fmt.Errorf("could not read systemd credential %s: %s", name, err)
+			}
+
+			emap.Set(envName(name), strings.TrimSuffix(string(bytes), "\n"))
+		}
+
+		return emap, nil
+	})
+}
+
+// envName turns a name from somewhere else (a credential, a tag...) into an env var name: upper cased
+// with anything that is not a letter or digit turned into a _
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
@@ -0,0 +1,66 @@
+package env
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+)
+
+/*
+SetTemporary sets the env var to value for the ttl, then sets it back to what it was before (or unsets it).
+The returned func reverts it right away, e.g. to end a maintenance window early, it is safe to call more than once.
+
+If something else changes the env var in the meantime it is left alone when the ttl is up
+*/
+func SetTemporary(key, value string, ttl time.Duration) (revert func(), err error) {
+	return SetTemporaryContext(context.Background(), key, value, ttl)
+}
+
+// SetTemporaryContext is SetTemporary but also reverts the env var when ctx is done. A ttl of 0 only reverts on ctx
+func SetTemporaryContext(ctx context.Context, key, value string, ttl time.Duration) (revert func(), err error) {
+	prev, wasSet := os.LookupEnv(key)
+
+	err = os.Setenv(key, value)
+	if err != nil {
+		return nil, err
+	}
+
+	var once sync.Once
+	done := make(chan struct{})
+
+	revert = func() {
+		once.Do(func() {
+			close(done)
+
+			if cur, ok := os.LookupEnv(key); !ok || cur != value {
+				return
+			}
+
+			if wasSet {
+				os.Setenv(key, prev)
+			} else {
+				os.Unsetenv(key)
+			}
+		})
+	}
+
+	go func() {
+		var expired <-chan time.Time
+		if ttl > 0 {
+			timer := time.NewTimer(ttl)
+			defer timer.Stop()
+
+			expired = timer.C
+		}
+
+		select {
+		case <-expired:
+		case <-ctx.Done():
+		case <-done:
+		}
+		revert()
+	}()
+
+	return revert, nil
+}
@@ -0,0 +1,470 @@
+package env
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+ParseTOML parses a TOML config file into env vars. Tables and dotted keys are flattened by joining
+their keys with an underscore and every key is upper cased, so
+
+	[database]
+	host = "localhost"
+	port = 5432
+
+sets DATABASE_HOST and DATABASE_PORT. Strings of every kind, numbers, bools, dates, arrays of values
+and inline tables are read, arrays of tables are an error. Arrays are joined with commas, an item with a
+comma, a quote or a line break is quoted the way GetStringSlice reads it. Like TOML says, a key or a
+table defined twice is an error, and so are two keys that flatten to the same env var
+*/
+func ParseTOML(data []byte) (*Map, error) {
+	return parseTOML(data, "_")
+}
+
+// parseTOML parses TOML, joining the keys of tables with sep
+func parseTOML(data []byte, sep string) (*Map, error) {
+	t := &tomlParser{
+		s:       strings.ReplaceAll(string(data), "\r\n", "\n"),
+		sep:     sep,
+		emap:    NewMap(),
+		defined: make(map[string]string),
+		tables:  make(map[string]bool),
+	}
+
+	err := t.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	return t.emap, nil
+}
+
+type tomlParser struct {
+	s    string
+	i    int
+	sep  string
+	emap *Map
+
+	// defined is the dotted path of the key every env var was set from, tables the tables with a header
+	defined map[string]string
+	tables  map[string]bool
+}
+
+func (t *tomlParser) errorf(format string, args ...interface{}) error {
+	line := strings.Count(t.s[:t.i], "\n") + 1
+
+	return fmt.Errorf("invalid toml on line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (t *tomlParser) parse() error {
+	var table []string
+
+	for {
+		t.skip(true)
+		if t.i == len(t.s) {
+			return nil
+		}
+
+		if t.s[t.i] == '[' {
+			if strings.HasPrefix(t.s[t.i:], "[[") {
+				return t.errorf("arrays of tables are not supported")
+			}
+			t.i++
+
+			t.space()
+			path, err := t.key()
+			if err != nil {
+				return err
+			}
+			t.space()
+
+			if t.i == len(t.s) || t.s[t.i] != ']' {
+				return t.errorf("expected ] after the table name")
+			}
+			t.i++
+
+			name := strings.Join(path, ".")
+			if t.tables[name] {
+				return t.errorf("table %s is defined twice", name)
+			}
+			t.tables[name] = true
+			table = path
+		} else {
+			path, err := t.key()
+			if err != nil {
+				return err
+			}
+
+			t.space()
+			if t.i == len(t.s) || t.s[t.i] != '=' {
+				return t.errorf("expected = after %s", strings.Join(path, "."))
+			}
+			t.i++
+			t.space()
+
+			err = t.value(append(append([]string(nil), table...), path...))
+			if err != nil {
+				return err
+			}
+		}
+
+		// only a comment can follow on the line
+		t.space()
+		if t.i < len(t.s) && t.s[t.i] == '#' {
+			t.comment()
+		}
+		if t.i < len(t.s) && t.s[t.i] != '\n' {
+			return t.errorf("unexpected %q", t.rest())
+		}
+	}
+}
+
+// rest returns what is left of the current line
+func (t *tomlParser) rest() string {
+	rest := t.s[t.i:]
+	if end := strings.IndexByte(rest, '\n'); end != -1 {
+		rest = rest[:end]
+	}
+
+	return rest
+}
+
+// space skips spaces and tabs
+func (t *tomlParser) space() {
+	for t.i < len(t.s) && (t.s[t.i] == ' ' || t.s[t.i] == '\t') {
+		t.i++
+	}
+}
+
+// comment skips to the end of the line
+func (t *tomlParser) comment() {
+	for t.i < len(t.s) && t.s[t.i] != '\n' {
+		t.i++
+	}
+}
+
+// skip skips spaces, comments and if lines is set line breaks
+func (t *tomlParser) skip(lines bool) {
+	for t.i < len(t.s) {
+		switch t.s[t.i] {
+		case ' ', '\t':
+			t.i++
+		case '#':
+			t.comment()
+		case '\n':
+			if !lines {
+				return
+			}
+			t.i++
+		default:
+			return
+		}
+	}
+}
+
+// key reads a dotted key like a."b".c
+func (t *tomlParser) key() ([]string, error) {
+	var path []string
+
+	for {
+		var part string
+
+		switch {
+		case t.i == len(t.s):
+			return nil, t.errorf("expected a key")
+		case t.s[t.i] == '"' || t.s[t.i] == '\'':
+			val, err := t.str()
+			if err != nil {
+				return nil, err
+			}
+			part = val
+		default:
+			start := t.i
+			for t.i < len(t.s) && isBareKey(t.s[t.i]) {
+				t.i++
+			}
+			if start == t.i {
+				return nil, t.errorf("expected a key, got %q", t.rest())
+			}
+			part = t.s[start:t.i]
+		}
+		path = append(path, part)
+
+		t.space()
+		if t.i == len(t.s) || t.s[t.i] != '.' {
+			return path, nil
+		}
+		t.i++
+		t.space()
+	}
+}
+
+func isBareKey(c byte) bool {
+	return c == '_' || c == '-' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// value reads the value of the key at path
+func (t *tomlParser) value(path []string) error {
+	if t.i == len(t.s) {
+		return t.errorf("expected a value")
+	}
+
+	switch t.s[t.i] {
+	case '{':
+		return t.inlineTable(path)
+	case '[':
+		items, err := t.array()
+		if err != nil {
+			return err
+		}
+		return t.set(path, joinList(items))
+	}
+
+	val, err := t.scalar()
+	if err != nil {
+		return err
+	}
+
+	return t.set(path, val)
+}
+
+// scalar reads a string, number, bool or date
+func (t *tomlParser) scalar() (string, error) {
+	switch t.s[t.i] {
+	case '"', '\'':
+		return t.str()
+	case '[', '{':
+		return "", t.errorf("only arrays of values are supported")
+	}
+
+	start := t.i
+	for t.i < len(t.s) && !strings.ContainsRune(" \t\n,]}#", rune(t.s[t.i])) {
+		t.i++
+	}
+
+	// dates can have a space between the date and time
+	if t.i+1 < len(t.s) && t.s[t.i] == ' ' && t.i-start == 10 && t.s[start+4] == '-' && t.s[t.i+1] >= '0' && t.s[t.i+1] <= '9' {
+		t.i++
+		for t.i < len(t.s) && !strings.ContainsRune(" \t\n,]}#", rune(t.s[t.i])) {
+			t.i++
+		}
+	}
+
+	val := t.s[start:t.i]
+	if val == "" {
+		return "", t.errorf("expected a value")
+	}
+
+	// 1_000 is 1000 to the programs reading the env
+	if strings.Contains(val, "_") {
+		if _, err := strconv.ParseFloat(strings.ReplaceAll(val, "_", ""), 64); err == nil {
+			val = strings.ReplaceAll(val, "_", "")
+		}
+	}
+
+	return val, nil
+}
+
+// array reads an array of values, which can span lines
+func (t *tomlParser) array() ([]string, error) {
+	var items []string
+	t.i++
+
+	for {
+		t.skip(true)
+		if t.i == len(t.s) {
+			return nil, t.errorf("array is never closed")
+		}
+		if t.s[t.i] == ']' {
+			t.i++
+			return items, nil
+		}
+
+		val, err := t.scalar()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, val)
+
+		t.skip(true)
+		if t.i < len(t.s) && t.s[t.i] == ',' {
+			t.i++
+		} else if t.i < len(t.s) && t.s[t.i] != ']' {
+			return nil, t.errorf("expected , or ] in array")
+		}
+	}
+}
+
+// inlineTable reads a { a = 1, b = 2 } table under path
+func (t *tomlParser) inlineTable(path []string) error {
+	t.i++
+
+	for {
+		t.space()
+		if t.i < len(t.s) && t.s[t.i] == '}' {
+			t.i++
+			return nil
+		}
+
+		key, err := t.key()
+		if err != nil {
+			return err
+		}
+
+		t.space()
+		if t.i == len(t.s) || t.s[t.i] != '=' {
+			return t.errorf("expected = after %s", strings.Join(key, "."))
+		}
+		t.i++
+		t.space()
+
+		err = t.value(append(append([]string(nil), path...), key...))
+		if err != nil {
+			return err
+		}
+
+		t.space()
+		switch {
+		case t.i < len(t.s) && t.s[t.i] == ',':
+			t.i++
+		case t.i < len(t.s) && t.s[t.i] == '}':
+		default:
+			return t.errorf("expected , or } in inline table")
+		}
+	}
+}
+
+// str reads a basic, literal or multiline string
+func (t *tomlParser) str() (string, error) {
+	quote := t.s[t.i : t.i+1]
+	multiline := strings.HasPrefix(t.s[t.i:], strings.Repeat(quote, 3))
+
+	if multiline {
+		t.i += 3
+		// a line break right after the opening quotes is not part of the string
+		if t.i < len(t.s) && t.s[t.i] == '\n' {
+			t.i++
+		}
+	} else {
+		t.i++
+	}
+
+	start := t.i
+	for ; t.i < len(t.s); t.i++ {
+		c := t.s[t.i]
+
+		if c == '\\' && quote == `"` {
+			t.i++
+			continue
+		}
+		if c == '\n' && !multiline {
+			break
+		}
+		if c != quote[0] {
+			continue
+		}
+
+		if !multiline {
+			raw := t.s[start:t.i]
+			t.i++
+			return t.unescape(raw, quote)
+		}
+
+		if strings.HasPrefix(t.s[t.i:], strings.Repeat(quote, 3)) {
+			// up to two quotes can end the string right before the closing ones
+			for strings.HasPrefix(t.s[t.i+1:], strings.Repeat(quote, 3)) {
+				t.i++
+			}
+
+			raw := t.s[start:t.i]
+			t.i += 3
+			return t.unescape(raw, quote)
+		}
+	}
+
+	return "", t.errorf("string is never closed")
+}
+
+// unescape replaces the escapes of a basic string, literal strings are taken as they are
+func (t *tomlParser) unescape(raw, quote string) (string, error) {
+	if quote == "'" || !strings.ContainsRune(raw, '\\') {
+		return raw, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(raw); i++ {
+		if raw[i] != '\\' {
+			b.WriteByte(raw[i])
+			continue
+		}
+
+		i++
+		if i == len(raw) {
+			return "", t.errorf("string ends with a backslash")
+		}
+
+		switch c := raw[i]; c {
+		case 'b':
+			b.WriteByte('\b')
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'f':
+			b.WriteByte('\f')
+		case 'r':
+			b.WriteByte('\r')
+		case '"', '\\':
+			b.WriteByte(c)
+		case 'u', 'U':
+			n := 4
+			if c == 'U' {
+				n = 8
+			}
+			if i+1+n > len(raw) {
+				return "", t.errorf("invalid escape \\%c", c)
+			}
+
+			r, err := strconv.ParseUint(raw[i+1:i+1+n], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(r)) {
+				return "", t.errorf("invalid escape \\%s", raw[i:i+1+n])
+			}
+			b.WriteRune(rune(r))
+			i += n
+		case ' ', '\t', '\n':
+			// a backslash at the end of a line in a multiline string trims the line break and the indentation after it
+			rest := strings.TrimLeft(raw[i:], " \t")
+			if !strings.HasPrefix(rest, "\n") {
+				return "", t.errorf("invalid escape \\%c", c)
+			}
+			i = len(raw) - len(strings.TrimLeft(rest, " \t\n")) - 1
+		default:
+			return "", t.errorf("invalid escape \\%c", c)
+		}
+	}
+
+	return b.String(), nil
+}
+
+// set sets the flattened key
+func (t *tomlParser) set(path []string, val string) error {
+	key, err := nestedKey(path, t.sep)
+	if err != nil {
+		return t.errorf("%s", err)
+	}
+
+	name := strings.Join(path, ".")
+	if first, ok := t.defined[key]; ok {
+		if first == name {
+			return t.errorf("%s is defined twice", name)
+		}
+		return t.errorf("%s and %s are both %s", first, name, key)
+	}
+	t.defined[key] = name
+
+	t.emap.Set(key, val)
+	return nil
+}
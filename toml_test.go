@@ -0,0 +1,45 @@
+package env
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTOMLArrays(t *testing.T) {
+	emap, err := ParseTOML([]byte(`hosts = ["a,b", 'say "hi"', "c"]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := parseList(emap.Map["HOSTS"])
+	if err != nil {
+		t.Fatalf("%q does not split: %s", emap.Map["HOSTS"], err)
+	}
+	if want := []string{"a,b", `say "hi"`, "c"}; !reflect.DeepEqual(items, want) {
+		t.Errorf("split %q into %q, want %q", emap.Map["HOSTS"], items, want)
+	}
+}
+
+func TestParseTOMLDuplicates(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"key", "port = 1\nport = 2", "port is defined twice"},
+		{"key in a table", "[db]\nport = 1\n[other]\n[db]\nport = 2", "table db is defined twice"},
+		{"dotted key", "db.port = 1\n[db]\nport = 2", "db.port is defined twice"},
+		{"inline table", "db = { port = 1, port = 2 }", "db.port is defined twice"},
+		{"flattened", "db_port = 1\n[db]\nport = 2", "db_port and db.port are both DB_PORT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTOML([]byte(tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want one with %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package env
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// cloudInitUserData is where cloud-init keeps the user data of the instance
+const cloudInitUserData = "/var/lib/cloud/instance/user-data.txt"
+
+/*
+ParseUserData pulls env vars out of the user data of a VM. Gzipped user data is unzipped first, then
+
+A #cloud-config document is searched for a top level env mapping, or list of KEY=value items
+
+	#cloud-config
+	env:
+	  APP_ENV: production
+	  DB_HOST: "10.0.0.5"
+
+Anything else is parsed as an env file, which includes shell scripts setting KEY=value lines
+*/
+func ParseUserData(data []byte) (*Map, error) {
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("could not unzip user data: %s", err)
+		}
+
+		data, err = ioutil.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("could not unzip user data: %s", err)
+		}
+	}
+
+	content := strings.ReplaceAll(string(data), "\r\n", "\n")
+	if !strings.HasPrefix(content, "#cloud-config") {
+		return Parse(content), nil
+	}
+
+	emap := NewMap()
+	inEnv := false
+
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		// a line that is not indented starts the next top level key
+		if line[0] != ' ' && line[0] != '\t' {
+			inEnv = strings.TrimRight(trimmed, " ") == "env:"
+			continue
+		}
+
+		if !inEnv {
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "- ") {
+			key, _, val, ok := parseLine(unquoteYAML(strings.TrimPrefix(trimmed, "- ")), false)
+			if !ok {
+				return nil, fmt.Errorf("could not parse cloud-config env item %q: expected KEY=value", trimmed)
+			}
+
+			emap.Set(key, val)
+			continue
+		}
+
+		splitLine := strings.SplitN(trimmed, ":", 2)
+		if len(splitLine) != 2 {
+			return nil, fmt.Errorf("could not parse cloud-config env line %q: expected KEY: value", trimmed)
+		}
+
+		emap.Set(strings.TrimSpace(splitLine[0]), unquoteYAML(strings.TrimSpace(splitLine[1])))
+	}
+
+	return emap, nil
+}
+
+// unquoteYAML strips the quotes around a yaml scalar
+func unquoteYAML(val string) string {
+	if len(val) >= 2 && (val[0] == '"' || val[0] == '\'') && val[len(val)-1] == val[0] {
+		return val[1 : len(val)-1]
+	}
+
+	return val
+}
+
+// CloudInitUserData returns an adapter that exports the env in the user data cloud-init saved on the VM,
+// see ParseUserData. An empty path uses /var/lib/cloud/instance/user-data.txt
+func CloudInitUserData(path string) *Adapter {
+	if path == "" {
+		path = cloudInitUserData
+	}
+
+	return &Adapter{
+		Name: "cloud-init",
+		Pull: func() (*Map, error) {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("could not read user data: %s", err)
+			}
+
+			return ParseUserData(data)
+		},
+	}
+}
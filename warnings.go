@@ -0,0 +1,195 @@
+package env
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// urlSchemes are the schemes Suspicious knows, a scheme that is one letter off from one of them is likely a typo
+var urlSchemes = []string{
+	"http", "https", "ws", "wss", "ftp", "sftp", "ssh", "git", "file", "s3", "gs",
+	"postgres", "postgresql", "mysql", "redis", "rediss", "mongodb", "mongodb+srv", "sqlite",
+	"amqp", "amqps", "kafka", "nats", "grpc", "smtp", "smtps", "ldap", "ldaps", "tcp", "udp",
+}
+
+/*
+Suspicious looks over the keys of r for values that are likely mistakes and returns a warning for
+each of them, sorted by key. It warns about:
+
+	quotes that ended up in the value, like "secret" with its quotes
+	whitespace around the value
+	URLs with a misspelled scheme, like htps://example.com or https//example.com
+	ports that are not a number from 0 to 65535, for keys named PORT or ending in _PORT
+	paths that do not exist, for keys ending in _FILE, _PATH or _DIR
+
+These are guesses, so they are only warnings. WithWarnings logs them on every load
+*/
+func Suspicious(r Reader) []Problem {
+	var warnings []Problem
+
+	for _, key := range r.Keys() {
+		val, _ := r.Lookup(key)
+
+		if message := suspicious(key, val); message != "" {
+			warnings = append(warnings, Problem{Key: key, Message: message})
+		}
+	}
+
+	return warnings
+}
+
+// suspicious returns what looks wrong about the value, or an empty string
+func suspicious(key, val string) string {
+	if val == "" {
+		return ""
+	}
+
+	if len(val) >= 2 && (val[0] == '"' || val[0] == '\'') && val[len(val)-1] == val[0] {
+		return fmt.Sprintf("is wrapped in %c quotes that are part of the value", val[0])
+	}
+	if strings.TrimSpace(val) != val {
+		return "has whitespace around the value"
+	}
+
+	if message := suspiciousURL(val); message != "" {
+		return message
+	}
+
+	name := strings.ToUpper(key)
+	if name == "PORT" || strings.HasSuffix(name, "_PORT") {
+		if port, err := strconv.Atoi(val); err != nil || port < 0 || port > 65535 {
+			return fmt.Sprintf("is not a port from 0 to 65535: %q", val)
+		}
+	}
+
+	if strings.HasSuffix(name, "_FILE") || strings.HasSuffix(name, "_PATH") || strings.HasSuffix(name, "_DIR") {
+		return suspiciousPath(val)
+	}
+
+	return ""
+}
+
+// suspiciousURL warns about a URL with a scheme that is one letter off from a known one, or missing the : or a /
+func suspiciousURL(val string) string {
+	for _, scheme := range urlSchemes {
+		for _, broken := range []string{scheme + "//", scheme + ":/"} {
+			if strings.HasPrefix(val, broken) && !strings.HasPrefix(val, scheme+"://") {
+				return fmt.Sprintf("looks like a %s URL but does not start with %s://", scheme, scheme)
+			}
+		}
+	}
+
+	i := strings.Index(val, "://")
+	if i <= 0 {
+		return ""
+	}
+
+	scheme := strings.ToLower(val[:i])
+	if hasString(urlSchemes, scheme) {
+		return ""
+	}
+
+	for _, known := range urlSchemes {
+		if oneEditApart(scheme, known) {
+			return fmt.Sprintf("has the URL scheme %q, did you mean %q?", scheme, known)
+		}
+	}
+
+	return ""
+}
+
+// suspiciousPath warns about a value that looks like a path but does not exist
+func suspiciousPath(val string) string {
+	if !strings.HasPrefix(val, "/") && !strings.HasPrefix(val, "./") && !strings.HasPrefix(val, "../") && !strings.HasPrefix(val, "~/") {
+		return ""
+	}
+	// a list of paths, like PATH itself
+	if strings.ContainsRune(val, os.PathListSeparator) {
+		return ""
+	}
+
+	path := val
+	if strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		path = filepath.Join(home, path[2:])
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return fmt.Sprintf("is the path %s, which does not exist", val)
+	}
+
+	return ""
+}
+
+// oneEditApart reports if a can be turned into b by adding, removing, changing or swapping one letter
+func oneEditApart(a, b string) bool {
+	if a == b {
+		return false
+	}
+
+	switch len(a) - len(b) {
+	case 0:
+		var diff []int
+		for i := 0; i < len(a); i++ {
+			if a[i] != b[i] {
+				diff = append(diff, i)
+			}
+		}
+
+		swapped := len(diff) == 2 && diff[1] == diff[0]+1 && a[diff[0]] == b[diff[1]] && a[diff[1]] == b[diff[0]]
+		return len(diff) == 1 || swapped
+	case 1:
+		return dropsOne(a, b)
+	case -1:
+		return dropsOne(b, a)
+	}
+
+	return false
+}
+
+// dropsOne reports if removing one letter from long gives short
+func dropsOne(long, short string) bool {
+	for i := 0; i < len(long); i++ {
+		if long[:i]+long[i+1:] == short {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+Sanitize trims the whitespace around the values of emap and strips a pair of quotes wrapping a value,
+the two mistakes copy pasted values come with most. It returns what it changed, sorted by key, so
+the changes can be reported. WithSanitize does this on every load and logs the changes
+*/
+func Sanitize(emap *Map) []Problem {
+	var changes []Problem
+
+	for _, key := range emap.Keys() {
+		val := emap.Map[key]
+
+		var changed []string
+		if trimmed := strings.TrimSpace(val); trimmed != val {
+			val = trimmed
+			changed = append(changed, "trimmed the whitespace around the value")
+		}
+		if len(val) >= 2 && (val[0] == '"' || val[0] == '\'') && val[len(val)-1] == val[0] {
+			changed = append(changed, fmt.Sprintf("removed the %c quotes around the value", val[0]))
+			val = strings.TrimSpace(val[1 : len(val)-1])
+		}
+
+		if len(changed) != 0 {
+			emap.Set(key, val)
+			changes = append(changes, Problem{Key: key, Message: strings.Join(changed, " and ")})
+		}
+	}
+
+	return changes
+}
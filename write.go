@@ -0,0 +1,329 @@
+package env
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// fileLocks holds a *sync.Mutex per absolute path so writes to the same file from this process take turns
+var fileLocks sync.Map
+
+// AppendToFile adds a KEY=value line to the end of the env file, creating the file if it does not exist
+func AppendToFile(path, key, value string) error {
+	line, err := formatLine(key, value)
+	if err != nil {
+		return err
+	}
+
+	return editFile(path, func(lines []string) []string {
+		return append(lines, line)
+	})
+}
+
+/*
+UpsertInFile sets the key in the env file. If the key is already in the file the line that sets it
+is replaced (the last one, if it is set more than once), otherwise a new line is added to the end
+*/
+func UpsertInFile(path, key, value string) error {
+	line, err := formatLine(key, value)
+	if err != nil {
+		return err
+	}
+
+	return editFile(path, func(lines []string) []string {
+		return upsert(lines, key, line)
+	})
+}
+
+// upsert replaces the lines of the last entry setting key with line, or adds line to the end
+func upsert(lines []string, key, line string) []string {
+	found := -1
+	entries := fileEntries(lines)
+	for i, e := range entries {
+		if e.key == key {
+			found = i
+		}
+	}
+
+	if found == -1 {
+		return append(lines, line)
+	}
+	e := entries[found]
+
+	// keep the file sourceable by a shell
+	if e.exported {
+		line = "export " + line
+	}
+
+	return append(lines[:e.start], append([]string{line}, lines[e.end+1:]...)...)
+}
+
+// fileEntry is a key set by lines start to end of a file, counting from 0
+type fileEntry struct {
+	key        string
+	start, end int
+	exported   bool
+}
+
+// fileEntries finds the lines setting each key, in the order they are in the file
+func fileEntries(lines []string) []fileEntry {
+	var entries []fileEntry
+
+	for i := 0; i < len(lines); i++ {
+		first := i
+		text := strings.Trim(lines[i], " ")
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		for continues(text) && i+1 < len(lines) {
+			i++
+			text = text[:len(text)-1] + strings.Trim(lines[i], " ")
+		}
+
+		// skip over the lines of a heredoc or a multiline quoted value, like the parser does
+		if _, _, val, ok := parseLine(text, true); ok && strings.HasPrefix(strings.TrimSpace(val), "<<") {
+			rest := newLineReader(strings.NewReader(strings.Join(lines[i+1:], "\n")))
+			if _, _, _, closed := readHeredoc(rest, val); closed {
+				i += rest.n
+			}
+		}
+		if _, _, val, ok := parseLine(text, true); ok && opensQuote(val) {
+			q := newQuoteScanner(val)
+			for j := i + 1; j < len(lines); j++ {
+				if q.closes(lines[j]) {
+					i = j
+					break
+				}
+			}
+		}
+
+		if k, _, _, ok := parseLine(text, true); ok {
+			entries = append(entries, fileEntry{key: k, start: first, end: i, exported: trimExport(text) != text})
+		}
+	}
+
+	return entries
+}
+
+/*
+WriteTo writes the map as an env file, a KEY=value line for every key in the order they were set (see
+Ordered) with its description as the comment above it, so the same map is always written the same way
+and parses back to the same keys and values
+*/
+func (e *Map) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+
+	for _, key := range e.Ordered() {
+		line, err := formatLine(key, e.Map[key])
+		if err != nil {
+			return 0, err
+		}
+
+		if description := e.Description(key); description != "" {
+			for _, comment := range strings.Split(description, "\n") {
+				b.WriteString(strings.TrimSpace("# "+comment) + "\n")
+			}
+		}
+		b.WriteString(line + "\n")
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// formatLine writes the key and value as a line that parses back to the same key and value
+func formatLine(key, value string) (string, error) {
+	// a key ending like an operator would parse back as KEY?=, KEY+= or KEY:=
+	if key == "" || strings.ContainsAny(key, "= \t\r\n#") || strings.ContainsAny(key[len(key)-1:], "?+:") {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+
+	// values that would not parse back as they are go in double quotes, with their line breaks escaped
+	if strings.ContainsAny(value, "\r\n\t") || continues(value) || strings.TrimSpace(value) != value ||
+		strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "'") || strings.Contains(value, " #") || strings.HasPrefix(value, "<<") {
+		return key + "=" + quote(value), nil
+	}
+
+	return key + "=" + value, nil
+}
+
+// quote double quotes the value, escaping what needs to be escaped
+func quote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(value) + `"`
+}
+
+// editFile reads the lines of the file, lets edit change them and writes them back atomically
+func editFile(path string, edit func(lines []string) []string) error {
+	return editLines(path, func(f *fileLines) error {
+		f.lines = edit(f.lines)
+		return nil
+	})
+}
+
+// editLines is editFile, where edit can also change the line endings and byte order mark. Nothing is
+// written when edit returns an error
+func editLines(path string, edit func(f *fileLines) error) error {
+	abs, err := editPath(path)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := lockEdit(abs)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	f, mode, err := readLines(abs)
+	if os.IsNotExist(err) {
+		f, mode, err = splitFile(nil), 0644, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = edit(&f)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(abs, f.join(), mode)
+}
+
+// lockEdit locks the file at the absolute path for an edit, against this process and others, until unlock
+func lockEdit(abs string) (unlock func(), err error) {
+	lock, _ := fileLocks.LoadOrStore(abs, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+
+	// and lock other processes out until the new file is in place
+	unlockFile, err := lockFile(abs, true)
+	if err != nil {
+		lock.(*sync.Mutex).Unlock()
+		return nil, err
+	}
+
+	return func() {
+		unlockFile()
+		lock.(*sync.Mutex).Unlock()
+	}, nil
+}
+
+// readLines reads the lines of the file and its permissions
+func readLines(path string) (fileLines, os.FileMode, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fileLines{}, 0, err
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	return splitFile(bytes), mode, nil
+}
+
+// fileLines are the lines of a file, with the line endings and byte order mark it had so files saved on
+// Windows are written back the way they were found
+type fileLines struct {
+	lines   []string
+	newline string
+	prefix  string
+}
+
+func splitFile(data []byte) fileLines {
+	f := fileLines{newline: "\n"}
+
+	content := string(data)
+	if strings.HasPrefix(content, bom) {
+		content, f.prefix = strings.TrimPrefix(content, bom), bom
+	}
+	if strings.Contains(content, "\r\n") {
+		content, f.newline = strings.ReplaceAll(content, "\r\n", "\n"), "\r\n"
+	}
+
+	content = strings.TrimSuffix(content, "\n")
+	if content != "" {
+		f.lines = strings.Split(content, "\n")
+	}
+
+	return f
+}
+
+func (f fileLines) join() []byte {
+	return []byte(f.prefix + strings.Join(f.lines, f.newline) + f.newline)
+}
+
+// writeFileAtomic writes to a temp file next to the file and renames it over the file, so readers never see half of a write
+func writeFileAtomic(path string, data []byte, mode os.FileMode) error {
+	// renaming over a symlink would replace the link, so the file it points to is written instead
+	path, err := resolveSymlinks(path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err == nil {
+		err = tmp.Chmod(mode)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// editPath is the absolute path of the file an edit of path changes, with symlinks resolved so edits
+// through a link and of the file it points to take the same lock
+func editPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	return resolveSymlinks(abs)
+}
+
+// resolveSymlinks returns the file path points to, following a link to a file that does not exist yet
+// as well, and path itself when it is not a link
+func resolveSymlinks(path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		return resolved, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	// EvalSymlinks fails on a link to a file that is not there, write it where the links lead
+	for i := 0; i < 255; i++ {
+		link, err := os.Readlink(path)
+		if err != nil {
+			return path, nil
+		}
+		if !filepath.IsAbs(link) {
+			link = filepath.Join(filepath.Dir(path), link)
+		}
+		path = link
+	}
+
+	return "", fmt.Errorf("could not resolve %s: too many links", path)
+}
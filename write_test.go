@@ -0,0 +1,82 @@
+package env
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEditThroughSymlink(t *testing.T) {
+	target := writeEnvFile(t, "A=1\n")
+	dir := filepath.Dir(target)
+
+	tests := []struct {
+		name   string
+		target string
+	}{
+		{"existing file", target},
+		{"file that does not exist yet", filepath.Join(dir, "missing.env")},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link := filepath.Join(dir, fmt.Sprintf("link%d.env", i))
+			if err := os.Symlink(filepath.Base(tt.target), link); err != nil {
+				t.Skipf("can not create symlinks: %s", err)
+			}
+
+			err := UpsertInFile(link, "B", "2")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := os.Lstat(link)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode()&os.ModeSymlink == 0 {
+				t.Fatalf("%s was replaced by a file", link)
+			}
+
+			content, err := ioutil.ReadFile(tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Parse(string(content)).Map["B"]; got != "2" {
+				t.Errorf("the file the link points to has B=%q, want 2:\n%s", got, content)
+			}
+		})
+	}
+}
+
+func TestEditKeepsWindowsFiles(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(path string) error
+	}{
+		{"UpsertInFile", func(path string) error { return UpsertInFile(path, "B", "3") }},
+		{"EditDocument", func(path string) error {
+			return EditDocument(path, func(doc *Document) error { return doc.Set("B", "3") })
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeEnvFile(t, "\ufeffA=1\r\nB=2\r\n")
+
+			if err := tt.edit(path); err != nil {
+				t.Fatal(err)
+			}
+
+			content, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := "\ufeffA=1\r\nB=3\r\n"; string(content) != want {
+				t.Errorf("the file is %q, want %q", content, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,386 @@
+package env
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+ParseYAML parses a YAML config file into env vars. Nested mappings are flattened by joining their
+keys with an underscore and every key is upper cased, so
+
+	database:
+	  host: localhost
+	  port: 5432
+	allowed_origins: [https://a.example.com, https://b.example.com]
+
+sets DATABASE_HOST, DATABASE_PORT and ALLOWED_ORIGINS. Lists are joined with commas, an item with a
+comma, a quote or a line break is quoted the way GetStringSlice reads it, so the items come back as
+they were. It reads the subset of YAML config files use: mappings, lists of values, plain and quoted
+scalars and | or > block scalars. Anchors, flow mappings and lists of mappings are an error
+*/
+func ParseYAML(data []byte) (*Map, error) {
+	return parseYAML(data, "_")
+}
+
+// parseYAML parses YAML, joining the keys of nested mappings with sep
+func parseYAML(data []byte, sep string) (*Map, error) {
+	content := strings.ReplaceAll(string(data), "\r\n", "\n")
+
+	y := &yamlParser{lines: strings.Split(content, "\n"), sep: sep, emap: NewMap()}
+
+	err := y.mapping(0, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if y.skip(); y.i < len(y.lines) {
+		return nil, y.errorf("unexpected indentation")
+	}
+
+	return y.emap, nil
+}
+
+type yamlParser struct {
+	lines []string
+	i     int
+	sep   string
+	emap  *Map
+}
+
+// errorf is an error on the current line
+func (y *yamlParser) errorf(format string, args ...interface{}) error {
+	return y.errorAt(y.i, format, args...)
+}
+
+// errorAt is an error on line i, counting from 0
+func (y *yamlParser) errorAt(i int, format string, args ...interface{}) error {
+	return fmt.Errorf("invalid yaml on line %d: %s", i+1, fmt.Sprintf(format, args...))
+}
+
+// skip moves past blank and comment lines
+func (y *yamlParser) skip() {
+	for ; y.i < len(y.lines); y.i++ {
+		text := strings.TrimSpace(y.lines[y.i])
+		if text != "" && !strings.HasPrefix(text, "#") {
+			return
+		}
+	}
+}
+
+// indentation returns the indentation of the current line, or -1 at the end
+func (y *yamlParser) indentation() int {
+	y.skip()
+	if y.i == len(y.lines) {
+		return -1
+	}
+
+	line := y.lines[y.i]
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// mapping reads the keys of a mapping indented by indent
+func (y *yamlParser) mapping(indent int, path []string) error {
+	for {
+		ind := y.indentation()
+		if ind < indent {
+			return nil
+		}
+		if ind > indent {
+			return y.errorf("unexpected indentation")
+		}
+
+		text := strings.TrimSpace(y.lines[y.i])
+		if strings.HasPrefix(y.lines[y.i], "\t") {
+			return y.errorf("tabs can not be used for indentation")
+		}
+
+		// document markers
+		if text == "---" || text == "..." {
+			y.i++
+			continue
+		}
+
+		if text == "-" || strings.HasPrefix(text, "- ") {
+			return y.errorf("expected a key, lists are only supported as values")
+		}
+
+		key, rest, err := splitYAMLKey(text)
+		if err != nil {
+			return y.errorf("%s", err)
+		}
+		at := y.i
+		y.i++
+
+		keyPath := append(append([]string(nil), path...), key)
+		rest = stripYAMLComment(rest)
+
+		switch {
+		case rest == "":
+			next := y.indentation()
+			isList := next != -1 && (strings.TrimSpace(y.lines[y.i]) == "-" || strings.HasPrefix(strings.TrimSpace(y.lines[y.i]), "- "))
+
+			switch {
+			case isList && next >= ind:
+				err = y.list(next, keyPath)
+			case next > ind:
+				err = y.mapping(next, keyPath)
+			default:
+				err = y.set(at, keyPath, "")
+			}
+		case rest[0] == '|' || rest[0] == '>':
+			err = y.blockScalar(at, ind, keyPath, rest)
+		case rest[0] == '[':
+			err = y.flowList(at, keyPath, rest)
+		case rest[0] == '{':
+			err = y.errorAt(at, "flow mappings are not supported, write %s as a nested mapping", key)
+		default:
+			var val string
+			val, err = yamlScalar(rest)
+			if err != nil {
+				err = y.errorAt(at, "%s", err)
+			} else {
+				err = y.set(at, keyPath, val)
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// list reads a list of values indented by indent and joins them with joinList
+func (y *yamlParser) list(indent int, path []string) error {
+	var items []string
+	at := y.i
+
+	for y.indentation() == indent {
+		text := strings.TrimSpace(y.lines[y.i])
+		if text != "-" && !strings.HasPrefix(text, "- ") {
+			break
+		}
+
+		item := stripYAMLComment(strings.TrimSpace(strings.TrimPrefix(text, "-")))
+		if _, _, err := splitYAMLKey(item); err == nil || strings.HasPrefix(item, "[") || strings.HasPrefix(item, "{") {
+			return y.errorf("only lists of values are supported")
+		}
+
+		val, err := yamlScalar(item)
+		if err != nil {
+			return y.errorf("%s", err)
+		}
+		items = append(items, val)
+		y.i++
+	}
+
+	return y.set(at, path, joinList(items))
+}
+
+// flowList reads a list written like [a, b, c] on line at
+func (y *yamlParser) flowList(at int, path []string, rest string) error {
+	if !strings.HasSuffix(rest, "]") {
+		return y.errorAt(at, "lists written in brackets must be on one line")
+	}
+
+	var items []string
+	for _, item := range splitYAMLFlow(rest[1 : len(rest)-1]) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		val, err := yamlScalar(item)
+		if err != nil {
+			return y.errorAt(at, "%s", err)
+		}
+		items = append(items, val)
+	}
+
+	return y.set(at, path, joinList(items))
+}
+
+// blockScalar reads a | or > value started on line at from the lines indented more than indent
+func (y *yamlParser) blockScalar(at, indent int, path []string, header string) error {
+	chomp := strings.TrimLeft(header[1:], "0123456789")
+	if chomp != "" && chomp != "-" && chomp != "+" {
+		return y.errorAt(at, "invalid block scalar %q", header)
+	}
+
+	var lines []string
+	blockIndent := -1
+	for ; y.i < len(y.lines); y.i++ {
+		line := y.lines[y.i]
+		text := strings.TrimSpace(line)
+		ind := len(line) - len(strings.TrimLeft(line, " "))
+
+		if text == "" {
+			lines = append(lines, "")
+			continue
+		}
+		if ind <= indent {
+			break
+		}
+		if blockIndent == -1 {
+			blockIndent = ind
+		}
+		if ind < blockIndent {
+			return y.errorf("block scalar lines must be indented alike")
+		}
+
+		lines = append(lines, line[blockIndent:])
+	}
+
+	// keep the trailing blank lines only for + chomping
+	trailing := 0
+	for len(lines) != 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+
+	var val string
+	if header[0] == '|' {
+		val = strings.Join(lines, "\n")
+	} else {
+		val = foldYAML(lines)
+	}
+
+	switch {
+	case len(lines) == 0:
+	case chomp == "+":
+		val += strings.Repeat("\n", trailing+1)
+	case chomp == "":
+		val += "\n"
+	}
+
+	return y.set(at, path, val)
+}
+
+// foldYAML joins the lines of a > block scalar, single line breaks become spaces
+func foldYAML(lines []string) string {
+	var b strings.Builder
+
+	for i, line := range lines {
+		switch {
+		case i == 0, line != "" && lines[i-1] == "":
+		case line == "":
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(' ')
+		}
+		b.WriteString(line)
+	}
+
+	return b.String()
+}
+
+// set sets the flattened key of the value on line at
+func (y *yamlParser) set(at int, path []string, val string) error {
+	key, err := nestedKey(path, y.sep)
+	if err != nil {
+		return y.errorAt(at, "%s", err)
+	}
+
+	y.emap.Set(key, val)
+	return nil
+}
+
+// splitYAMLKey splits `key: value` into the key and the rest of the line
+func splitYAMLKey(text string) (string, string, error) {
+	if text != "" && (text[0] == '"' || text[0] == '\'') {
+		end := strings.IndexByte(text[1:], text[0])
+		if end == -1 {
+			return "", "", fmt.Errorf("unterminated quote")
+		}
+		end++
+
+		rest := text[end+1:]
+		if rest != ":" && !strings.HasPrefix(rest, ": ") {
+			return "", "", fmt.Errorf("expected a : after %s", text[:end+1])
+		}
+
+		return text[1:end], strings.TrimSpace(rest[1:]), nil
+	}
+
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), nil
+		}
+	}
+
+	return "", "", fmt.Errorf("expected key: value")
+}
+
+// stripYAMLComment cuts a # comment off the end of a value, outside of quotes
+func stripYAMLComment(s string) string {
+	var quote byte
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || s[i-1] == ' ' || s[i-1] == '[' || s[i-1] == ',' {
+				quote = c
+			}
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return strings.TrimSpace(s[:i])
+		}
+	}
+
+	return strings.TrimSpace(s)
+}
+
+// splitYAMLFlow splits the items of a [a, b] list on the commas outside of quotes
+func splitYAMLFlow(s string) []string {
+	var items []string
+	var quote byte
+	start := 0
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(items, s[start:])
+}
+
+// yamlScalar returns the value of a plain or quoted scalar
+func yamlScalar(s string) (string, error) {
+	if s == "" || s == "~" || s == "null" {
+		return "", nil
+	}
+
+	if s != "" && (s[0] == '"' || s[0] == '\'') {
+		if len(s) < 2 || s[len(s)-1] != s[0] {
+			return "", fmt.Errorf("unterminated quote in %s", s)
+		}
+
+		if s[0] == '\'' {
+			return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+		}
+
+		return unescape(s[1 : len(s)-1]), nil
+	}
+
+	if s[0] == '&' || s[0] == '*' {
+		return "", fmt.Errorf("anchors and aliases are not supported")
+	}
+
+	return s, nil
+}
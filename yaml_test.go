@@ -0,0 +1,36 @@
+package env
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseYAMLLists(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"plain", "hosts: [a, b]", []string{"a", "b"}},
+		{"comma in an item", "hosts: [\"a,b\", c]", []string{"a,b", "c"}},
+		{"quote in an item", "hosts:\n  - 'say \"hi\"'\n  - c", []string{`say "hi"`, "c"}},
+		{"line break in an item", "hosts:\n  - \"a\\nb\"\n  - c", []string{"a\nb", "c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			emap, err := ParseYAML([]byte(tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			items, err := parseList(emap.Map["HOSTS"])
+			if err != nil {
+				t.Fatalf("%q does not split: %s", emap.Map["HOSTS"], err)
+			}
+			if !reflect.DeepEqual(items, tt.want) {
+				t.Errorf("split %q into %q, want %q", emap.Map["HOSTS"], items, tt.want)
+			}
+		})
+	}
+}